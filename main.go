@@ -2,16 +2,17 @@ package main
 
 import (
 	"fmt"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/analysis"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/filter"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/load"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/report"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/watch"
 	"github.com/spf13/cobra"
-	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
-	"io/fs"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/kubernetes/scheme"
-	"knative.dev/pkg/apis"
+	"k8s.io/apimachinery/pkg/labels"
 	"os"
-	"path/filepath"
-	"sort"
-	"strings"
+	"os/signal"
+	"runtime"
+	"syscall"
 	"time"
 )
 
@@ -23,16 +24,44 @@ func main() {
 		Run: func(cmd *cobra.Command, args []string) {
 			cmd.Help()
 		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return parseFilterOptions(time.Now())
+		},
 	}
-	tapa.PersistentFlags().StringVarP(&outputType, "output-type", "t", OutputTypeText, "output type, one of: text, csv")
+	tapa.PersistentFlags().StringVarP(&outputType, "output-type", "t", report.OutputTypeText, "output type, one of: text, csv, json, yaml")
+	tapa.PersistentFlags().BoolVar(&summaryOnly, "summary-only", summaryOnly, "only print the summary statistics and not each object")
+	tapa.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", kubeconfig,
+		"the kubeconfig file of the cluster to list objects from when no file is given, defaulting to $KUBECONFIG or ~/.kube/config")
+	tapa.PersistentFlags().StringVar(&kubeContext, "context", kubeContext,
+		"the kubeconfig context of the cluster to list objects from when no file is given, defaulting to the current context")
+	tapa.PersistentFlags().StringVar(&resultsURL, "results-url", resultsURL,
+		"the url of the Tekton Results API to list archived pipelineruns and taskruns from instead of files or the cluster")
+	tapa.PersistentFlags().StringVar(&resultsToken, "results-token", resultsToken,
+		"the bearer token to authenticate to the Tekton Results API with")
+	tapa.PersistentFlags().IntVar(&workers, "workers", workers, "the number of files read and decoded at once")
+	tapa.PersistentFlags().StringVar(&since, "since", since,
+		"only analyze objects that started or completed at or after this RFC3339 time, or this duration ago like 2h")
+	tapa.PersistentFlags().StringVar(&until, "until", until,
+		"only analyze objects that started or completed at or before this RFC3339 time, or this duration ago like 30m")
+	tapa.PersistentFlags().StringVarP(&namespace, "namespace", "n", namespace, "only analyze objects in this namespace, which is also the only namespace listed from a cluster")
+	tapa.PersistentFlags().StringVar(&name, "name", name,
+		"only analyze pipelineruns, and the taskruns and pods of pipelineruns, whose name matches this glob, or regular expression between slashes like /^build-[0-9]+$/")
+	tapa.PersistentFlags().StringVarP(&selector, "selector", "l", selector, "only analyze objects whose labels match this label selector")
+	tapa.PersistentFlags().StringSliceVar(&statuses, "status", statuses,
+		"only analyze objects with one of these statuses, the Succeeded condition reason of pipelineruns and taskruns like Failed, Cancelled or PipelineRunTimeout, or the phase of pods")
+	tapa.PersistentFlags().StringVar(&groupBy, "group-by", groupBy,
+		"print the statistics of each group of objects instead of each object, grouped by one of: pipeline, task, namespace, or any label key")
 	tapa.ParseFlags(os.Args)
 
 	tapa.AddCommand(ParsePipelineRunList())
 	tapa.AddCommand(ParseTaskRunList())
 	tapa.AddCommand(ParsePodList())
 	tapa.AddCommand(ParseAllThreeLists())
+	tapa.AddCommand(ParseCriticalPath())
+	tapa.AddCommand(ClusterFailures())
+	tapa.AddCommand(Watch())
 
-	if outputType != OutputTypeText && outputType != OutputTypeCsv {
+	if !report.ValidOutputType(outputType) {
 		tapa.Help()
 		fmt.Fprintf(os.Stderr, "Error: Invalid value for output-type: %s\n", outputType)
 		os.Exit(1)
@@ -44,746 +73,455 @@ func main() {
 	}
 }
 
-var prStartTimes = map[string]time.Time{}
-var prEndTimes = map[string]time.Time{}
-var trStartTimes = map[string]time.Time{}
-var trEndTimes = map[string]time.Time{}
-var podStartTimes = map[string]time.Time{}
-var podEndTimes = map[string]time.Time{}
-var containerStartTimes = map[string]time.Time{}
-var containerEndTimmes = map[string]time.Time{}
-
-var prToDuration = map[string]float64{}
-var prDurations = []float64{}
-var prDurationsMap = map[float64]struct{}{}
-var podToDuration = map[string]float64{}
-var podDurations = []float64{}
-var podDurationsMap = map[float64]struct{}{}
-var trToDuration = map[string]float64{}
-var trDurations = []float64{}
-var trDurationsMap = map[float64]struct{}{}
-var containerToDuration = map[string]float64{}
-var containerDurations = []float64{}
-var containerDurationsMap = map[float64]struct{}{}
-
-const (
-	OutputTypeText string = "text"
-	OutputTypeCsv  string = "csv"
-)
-
 var (
-	outputType    = OutputTypeText
+	outputType    = report.OutputTypeText
 	containerOnly = false
+	latency       = false
+	stepsOnly     = false
 	whoFailed     = false
+	logLines      = 20
+	examples      = 3
+	interval      = time.Minute
+	stateFile     = ""
+	summaryOnly   = false
+	kubeconfig    = ""
+	kubeContext   = ""
+	resultsURL    = ""
+	resultsToken  = ""
+	workers       = runtime.GOMAXPROCS(0)
+	since         = ""
+	until         = ""
+	namespace     = ""
+	name          = ""
+	selector      = ""
+	groupBy       = ""
+	statuses      = []string{}
+	filterOptions = filter.Options{}
 )
 
-func processPRFiles(fileName string) (*v1beta1.PipelineRunList, error) {
-	var err error
-	prList := &v1beta1.PipelineRunList{}
-	prList.Items = []v1beta1.PipelineRun{}
-	v1beta1.AddToScheme(scheme.Scheme)
-	decoder := scheme.Codecs.UniversalDecoder()
-
-	err = filepath.Walk(fileName, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "filepath walk error: %s\n", err.Error())
-			return nil
-		}
-		if !info.IsDir() {
-			buf, e := os.ReadFile(path)
-			if e != nil {
-				fmt.Fprintf(os.Stderr, "problem reading %s: %s\n", path, e.Error())
-				return nil
-			}
-			prl := &v1beta1.PipelineRunList{}
-			_, _, e1 := decoder.Decode(buf, nil, prl)
-			pipelineRun := &v1beta1.PipelineRun{}
-			_, _, e2 := decoder.Decode(buf, nil, pipelineRun)
-			if e1 != nil && e2 != nil {
-				return nil
-			}
-			if len(prl.Items) > 0 {
-				// unmarshall is not a perfect type filter
-				for _, pr := range prl.Items {
-					if pr.Kind != "PipelineRun" {
-						continue
-					}
-					prList.Items = append(prList.Items, pr)
-				}
-			} else {
-				prList.Items = append(prList.Items, *pipelineRun)
-			}
-		}
-		return nil
-	})
-
-	return prList, err
-}
-
-func processTRFiles(fileName string) (*v1beta1.TaskRunList, error) {
-	var err error
-	trList := &v1beta1.TaskRunList{}
-	trList.Items = []v1beta1.TaskRun{}
-	v1beta1.AddToScheme(scheme.Scheme)
-	decoder := scheme.Codecs.UniversalDecoder()
-
-	err = filepath.Walk(fileName, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "filepath walk error: %s\n", err.Error())
-			return nil
-		}
-		if !info.IsDir() {
-			buf, e := os.ReadFile(path)
-			if e != nil {
-				fmt.Fprintf(os.Stderr, "problem reading %s: %s\n", path, e.Error())
-				return nil
-			}
-			trl := &v1beta1.TaskRunList{}
-			_, _, e1 := decoder.Decode(buf, nil, trl)
-			taskRun := &v1beta1.TaskRun{}
-			_, _, e2 := decoder.Decode(buf, nil, taskRun)
-			if e1 != nil && e2 != nil {
-				return nil
-			}
-			if len(trl.Items) > 0 {
-				// unmarshall is not a perfect type filter
-				for _, tr := range trl.Items {
-					if tr.Kind != "TaskRun" {
-						continue
-					}
-					trList.Items = append(trList.Items, tr)
-				}
-			} else {
-				trList.Items = append(trList.Items, *taskRun)
-			}
-		}
-		return nil
-	})
-
-	return trList, err
-}
-
-func processPodFiles(fileName string) (*corev1.PodList, error) {
-	var err error
-	podList := &corev1.PodList{}
-	podList.Items = []corev1.Pod{}
-	corev1.AddToScheme(scheme.Scheme)
-	decoder := scheme.Codecs.UniversalDecoder()
-
-	err = filepath.Walk(fileName, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "filepath walk error: %s\n", err.Error())
-			return nil
-		}
-		if !info.IsDir() {
-			buf, e := os.ReadFile(path)
-			if e != nil {
-				fmt.Fprintf(os.Stderr, "problem reading %s: %s\n", path, e.Error())
-				return nil
-			}
-			pl := &corev1.PodList{}
-			_, _, e1 := decoder.Decode(buf, nil, pl)
-			p := &corev1.Pod{}
-			_, _, e2 := decoder.Decode(buf, nil, p)
-			if e1 != nil && e2 != nil {
-				return nil
-			}
-			if len(pl.Items) > 0 {
-				for _, pod := range pl.Items {
-					if pod.Kind != "Pod" {
-						continue
-					}
-					podList.Items = append(podList.Items, pod)
-				}
-			} else {
-				podList.Items = append(podList.Items, *p)
-			}
-		}
-		return nil
-	})
-
-	return podList, err
-}
-
-func ignorePipelineRun(pr *v1beta1.PipelineRun, prFilter string) bool {
-	prKey := fmt.Sprintf("%s:%s", pr.Namespace, pr.Name)
-	if len(prFilter) > 0 && prKey != prFilter {
-		return true
-	}
-	if !pr.HasStarted() {
-		return true
-	}
-	if !pr.IsDone() {
-		return true
-	}
-	return false
-}
-
-func ignoreTaskRun(tr *v1beta1.TaskRun, prFilter string) bool {
-	if !tr.HasStarted() {
-		return true
-	}
-	if !tr.IsDone() {
-		return true
-	}
-	trKey := fmt.Sprintf("%s:%s", tr.Namespace, tr.Name)
-	if len(prFilter) > 0 && !strings.HasPrefix(trKey, prFilter) {
-		return true
-	}
-	return false
-}
-
-func ignorePod(pod *corev1.Pod, prFilter string) bool {
-	if pod.Status.StartTime == nil {
-		return true
-	}
-	if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
-		return true
-	}
-	_, ok := pod.Labels["tekton.dev/pipelineRun"]
-	if !ok {
-		return true
-	}
-	podKey := fmt.Sprintf("%s:%s", pod.Namespace, pod.Name)
-	if len(prFilter) > 0 && !strings.HasPrefix(podKey, prFilter) {
-		return true
-	}
-	return false
-}
-
-func processPipelineRun(pr *v1beta1.PipelineRun) time.Duration {
-	duration := pr.Status.CompletionTime.Sub(pr.Status.StartTime.Time)
-	prKey := fmt.Sprintf("%s:%s", pr.Namespace, pr.Name)
-	prToDuration[prKey] = duration.Seconds()
-	_, ok := prDurationsMap[duration.Seconds()]
-	if !ok {
-		prDurations = append(prDurations, duration.Seconds())
-		prDurationsMap[duration.Seconds()] = struct{}{}
-	}
-	prStartTimes[prKey] = pr.Status.StartTime.Time
-	prEndTimes[prKey] = pr.Status.CompletionTime.Time
-	return duration
-}
-
-func processTaskRun(tr *v1beta1.TaskRun) time.Duration {
-	duration := tr.Status.CompletionTime.Sub(tr.Status.StartTime.Time)
-	trKey := fmt.Sprintf("%s:%s", tr.Namespace, tr.Name)
-	trToDuration[trKey] = duration.Seconds()
-	_, ok := trDurationsMap[duration.Seconds()]
-	if !ok {
-		trDurations = append(trDurations, duration.Seconds())
-		trDurationsMap[duration.Seconds()] = struct{}{}
-	}
-	trStartTimes[trKey] = tr.Status.StartTime.Time
-	trEndTimes[trKey] = tr.Status.CompletionTime.Time
-	return duration
-}
-
-func processPod(pod *corev1.Pod) time.Duration {
-	var terimnatedTime time.Time
-	for _, status := range pod.Status.ContainerStatuses {
-		terminated := status.State.Terminated
-		if terminated != nil {
-			if terminated.FinishedAt.Time.After(terimnatedTime) {
-				terimnatedTime = terminated.FinishedAt.Time
-			}
-		}
-	}
-	duration := terimnatedTime.Sub(pod.Status.StartTime.Time)
-	podKey := fmt.Sprintf("%s:%s", pod.Namespace, pod.Name)
-	podToDuration[podKey] = duration.Seconds()
-	podStartTimes[podKey] = pod.Status.StartTime.Time
-	podEndTimes[podKey] = terimnatedTime
-	_, ok := podDurationsMap[duration.Seconds()]
-	if !ok {
-		podDurations = append(podDurations, duration.Seconds())
-		podDurationsMap[duration.Seconds()] = struct{}{}
-	}
-	return duration
-}
-
-func processContainers(pod *corev1.Pod) []time.Duration {
-	durations := []time.Duration{}
-	specNameToIndex := map[string]int{}
-	statusNameToIndex := map[string]int{}
-	for index, container := range pod.Spec.Containers {
-		specNameToIndex[container.Name] = index
-	}
-	for index, cstatus := range pod.Status.ContainerStatuses {
-		statusNameToIndex[cstatus.Name] = index
-	}
-	for _, cstatus := range pod.Status.ContainerStatuses {
-		terminated := cstatus.State.Terminated
-		if terminated == nil {
-			continue
-		}
-		// containers are created started concurrently, but k8s/linux "pauses" then "resumes" per spec order
-		// so we take that finish time of the prior container if not the first container
-		started := terminated.StartedAt.Time
-		specIndex, _ := specNameToIndex[cstatus.Name]
-		if specIndex != 0 {
-			// not first container, get prior container finish time
-			priorContainerName := pod.Spec.Containers[specIndex-1].Name
-			priorContainerStatusIndex, _ := statusNameToIndex[priorContainerName]
-			priorContainerStatus := pod.Status.ContainerStatuses[priorContainerStatusIndex]
-			if priorContainerStatus.State.Terminated != nil {
-				started = priorContainerStatus.State.Terminated.FinishedAt.Time
-			}
-		}
-		finished := terminated.FinishedAt.Time
-		duration := finished.Sub(started)
-		ckey := fmt.Sprintf("%s:%s-%s", pod.Namespace, pod.Name, cstatus.Name)
-		containerToDuration[ckey] = duration.Seconds()
-		containerStartTimes[ckey] = started
-		containerEndTimmes[ckey] = finished
-		_, ok := containerDurationsMap[duration.Seconds()]
-		if !ok {
-			containerDurations = append(containerDurations, duration.Seconds())
-			containerDurationsMap[duration.Seconds()] = struct{}{}
-		}
-	}
-	return durations
-}
-
-func determinePRConcurrency(prKey string) int {
-	return innerConcurrency(prKey, prStartTimes, prEndTimes)
-}
-
-func determineTRConcurrency(trKey string) int {
-	return innerConcurrency(trKey, trStartTimes, trEndTimes)
-}
-
-func determinePodConcurrency(prKey string) int {
-	return innerConcurrency(prKey, podStartTimes, podEndTimes)
-}
-
-func determineContainerConcurrency(ckey string) int {
-	return innerConcurrency(ckey, containerStartTimes, containerEndTimmes)
-}
-
-func innerConcurrency(key string, starts map[string]time.Time, ends map[string]time.Time) int {
-	st, _ := starts[key]
-	en, _ := ends[key]
-	total := 1
-	for k, start := range starts {
-		if k == key {
-			continue
-		}
-		end, _ := ends[k]
-		if start.Equal(st) && end.Equal(en) {
-			total++
-			continue
-		}
-		if start.Before(en) && end.After(st) {
-			total++
-		}
-	}
-	return total
-}
-
-func findFailedPipelineRns(fileName, prFilter string) ([]string, []string) {
-	nslist := []string{}
-	namelist := []string{}
-	prList, err := processPRFiles(fileName)
-	if err != nil {
-		return []string{fmt.Sprintf("ERROR: problem reading file %s: %s\n", fileName, err.Error())}, nil
-	}
-
-	for _, pr := range prList.Items {
-		if ignorePipelineRun(&pr, prFilter) {
-			continue
-		}
-		if !pr.IsDone() {
-			fmt.Fprintf(os.Stderr, "PipelineRun %s:%s is not done\n", pr.Namespace, pr.Name)
-			continue
-		}
-		succeedCondition := pr.Status.GetCondition(apis.ConditionSucceeded)
-		// IsDone guarantees that the success condition is not nil
-		if succeedCondition.IsFalse() {
-			fmt.Fprintf(os.Stdout, "PipelineRun %s:%s failed\n", pr.Namespace, pr.Name)
-			nslist = append(nslist, pr.Namespace)
-			namelist = append(namelist, pr.Name)
-		}
-	}
-	return nslist, namelist
-}
-
-func findPodLogsForPipelineRun(ns, name, fileName string) error {
-	err := filepath.Walk(fileName, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "filepath walk error: %s\n", err.Error())
-			return nil
-		}
-		if !info.IsDir() {
-			if !strings.HasSuffix(path, ".log") {
-				return nil
-			}
-			if !strings.Contains(path, ns) {
-				return nil
-			}
-			if !strings.Contains(path, name) {
-				return nil
-			}
-			buf, e := os.ReadFile(path)
-			if e != nil {
-				fmt.Fprintf(os.Stderr, "problem reading %s: %s\n", path, e.Error())
-				return nil
-			}
-			fmt.Fprintf(os.Stdout, "PipelineRun %s:%s pod file %s has contents:\n %s\n", ns, name, info.Name(), string(buf))
-		}
-		return nil
-	})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error finding pod logs: %s\n", err.Error())
-	}
-	return err
-}
-
-func parsePipelineRunList(fileName, prFilter string) ([]string, []float64, []int, bool) {
-	prList, err := processPRFiles(fileName)
-	if err != nil {
-		return []string{fmt.Sprintf("ERROR: problem reading file %s: %s\n", fileName, err.Error())}, nil, nil, false
-	}
-
-	for _, pr := range prList.Items {
-		if ignorePipelineRun(&pr, prFilter) {
-			continue
-		}
-		processPipelineRun(&pr)
-	}
-	sort.Float64s(prDurations)
-	retS := []string{}
-	retF := []float64{}
-	retI := []int{}
-	for _, duration := range prDurations {
-		for key, value := range prToDuration {
-			if value == duration {
-				retS = append(retS, key)
-				retF = append(retF, value)
-				retI = append(retI, determinePRConcurrency(key))
-			}
-		}
-	}
-	return retS, retF, retI, true
-}
-
 func ParsePipelineRunList() *cobra.Command {
 	parsePRList := &cobra.Command{
-		Use:   "prlist <file location or directory tree with files> [<options>]",
+		Use:   "prlist [<file location or directory tree with files>] [<options>]",
 		Short: "Parse a list of Tekton PipelineRuns for various statistics",
 		Long:  "Parse a list of Tekton PipelineRuns for various statistics",
 		Example: `
 # Print the runtime stats
 $ tapa prlist <pipelinerun list json/yaml files or directory with files>
 
-# Print the pipelineruns that failed
-$ tapa prlist <pipelinerun list json/yaml files or directory with files> --who-failed
+# Print the runtime stats of the pipelineruns of a namespace of the cluster of a kubeconfig context
+$ tapa prlist --context <kubeconfig context> --namespace <namespace>
+
+# Print the runtime stats of the pipelineruns of a namespace archived by Tekton Results
+$ tapa prlist --results-url <results api url> --results-token <token> --namespace <namespace>
+
+# Print the pipelineruns that failed, with their failed taskruns and steps and the end of the log of each step
+$ tapa prlist <directory with pipelinerun and taskrun json/yaml files and container .log files> --who-failed
+
+# Print only the duration percentiles, mean, standard deviation, min, max and peak concurrency
+$ tapa prlist <pipelinerun list json/yaml files or directory with files> --summary-only
+
+# Print the duration percentiles, mean, standard deviation, min, max and peak concurrency of each pipeline
+$ tapa prlist <pipelinerun list json/yaml files or directory with files> --group-by pipeline
 `,
 		Run: func(cmd *cobra.Command, args []string) {
-			if len(args) == 0 {
-				fmt.Fprintf(os.Stderr, "ERROR: not enough arguments: %s\n", cmd.Use)
+			srcs, err := sources(cmd, args, 1)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
 				return
 			}
-			fileName := args[0]
+			fileName := sourceName(args, 0)
 			if whoFailed {
-				prns, prname := findFailedPipelineRns(fileName, "")
-				for i, ns := range prns {
-					name := prname[i]
-					findPodLogsForPipelineRun(ns, name, fileName)
+				failures, err := analysis.ParseFailures(srcs[0], filterOptions, logLines)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: problem reading file %s: %s\n", fileName, err.Error())
+					return
 				}
+				printer := report.NewPrinter(outputType, os.Stdout)
+				printer.PrintFailures(failures)
+				flush(printer)
 				return
 			}
-			retS, retF, retI, ok := parsePipelineRunList(fileName, "")
-			w := os.Stdout
-			if !ok {
-				w = os.Stderr
-				for _, str := range retS {
-					fmt.Fprintf(w, str)
-				}
+			analyzer, err := analysis.ParsePipelineRunList(srcs[0], filterOptions)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: problem reading file %s: %s\n", fileName, err.Error())
+				return
 			}
-			printList("PipelineRun", retS, retF, retI)
+			printer := report.NewPrinter(outputType, os.Stdout)
+			printRecords(printer, "PipelineRun", analyzer.PipelineRuns())
 		},
 	}
 	parsePRList.Flags().BoolVar(&whoFailed, "who-failed", whoFailed,
-		"Only list pipelineruns that failed")
+		"Only list pipelineruns that failed, along with their failed taskruns and steps")
+	parsePRList.Flags().IntVar(&logLines, "log-lines", logLines,
+		"The number of lines from the end of the log of each failed step to list with --who-failed")
 	return parsePRList
 }
 
-func parsePodList(fileName, prFilter string) ([]string, []float64, []int, bool) {
-	podList, err := processPodFiles(fileName)
-	if err != nil {
-		return []string{fmt.Sprintf("ERROR: file %s not marshalling into a Pod list: %s\n", fileName, err.Error())}, nil, nil, false
-	}
-
-	for _, pod := range podList.Items {
-		if ignorePod(&pod, prFilter) {
-			continue
-		}
-
-		if !containerOnly {
-			processPod(&pod)
-		} else {
-			processContainers(&pod)
-		}
-	}
-	retS := []string{}
-	retF := []float64{}
-	retI := []int{}
-	if !containerOnly {
-		sort.Float64s(podDurations)
-		for _, duration := range podDurations {
-			for key, value := range podToDuration {
-				if value == duration {
-					retS = append(retS, key)
-					retF = append(retF, value)
-					retI = append(retI, determinePodConcurrency(key))
-				}
-			}
-		}
-	}
-	if containerOnly {
-		sort.Float64s(containerDurations)
-		for _, duration := range containerDurations {
-			for key, value := range containerToDuration {
-				if value == duration {
-					retS = append(retS, key)
-					retF = append(retF, value)
-					retI = append(retI, determineContainerConcurrency(key))
-				}
-			}
-		}
-	}
-	return retS, retF, retI, true
-}
-
 func ParsePodList() *cobra.Command {
 	parsePodListCmd := &cobra.Command{
-		Use:   "podlist <file location or directory tree with files> [<options>]",
+		Use:   "podlist [<file location or directory tree with files>] [<options>]",
 		Short: "Parse a list of Pods for various statistics",
 		Long:  "Parse a list of Pods for various statistics",
 		Example: `
 # Print just the pods
 $ tapa podlist <pod list json/yaml file or directory with files>
 
-# Print just the containers
+# Print just the containers, with the sidecars listed apart from the sequential steps
 $ tapa podlist <pod list json/yaml file or directory with files> --containers-only
+
+# Print the scheduling, initialization, image pull, container start and execution time of each pod and of each pipelinerun
+$ tapa podlist <pod list json/yaml file or directory with files> --latency
 `,
 		Run: func(cmd *cobra.Command, args []string) {
-			if len(args) == 0 {
-				fmt.Fprintf(os.Stderr, "ERROR: not enough arguments: %s\n", cmd.Use)
+			srcs, err := sources(cmd, args, 1)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
 				return
 			}
-			fileName := args[0]
-			retS, retF, retI, ok := parsePodList(fileName, "")
-			w := os.Stdout
-			if !ok {
-				w = os.Stderr
-				for _, str := range retS {
-					fmt.Fprintf(w, str)
+			fileName := sourceName(args, 0)
+			analyzer, err := analysis.ParsePodList(srcs[0], filterOptions)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: file %s not marshalling into a Pod list: %s\n", fileName, err.Error())
+				return
+			}
+			printer := report.NewPrinter(outputType, os.Stdout)
+			if latency {
+				latencies := analyzer.PodLatencies()
+				if !summaryOnly {
+					printer.PrintPodLatencies(latencies)
 				}
+				printer.PrintPipelineRunLatencies(analysis.LatenciesByPipelineRun(latencies))
+				flush(printer)
+				return
+			}
+			if containerOnly {
+				printRecordLists(printer, []string{"Pod", "Sidecar"}, [][]analysis.Record{analyzer.Containers(), analyzer.Sidecars()})
 				return
 			}
-			printList("Pod", retS, retF, retI)
+			printRecords(printer, "Pod", analyzer.Pods())
 		},
 	}
 	parsePodListCmd.Flags().BoolVar(&containerOnly, "containers-only", containerOnly,
-		"Only list containers and not pods")
+		"Only list containers, and sidecars apart from them, and not pods")
+	parsePodListCmd.Flags().BoolVar(&latency, "latency", latency,
+		"List the scheduling latency breakdown of each pod and their totals per pipelinerun")
 	return parsePodListCmd
 }
 
-func parseTaskRunList(fileName, prFilter string) ([]string, []float64, []int, bool) {
-	trList, err := processTRFiles(fileName)
-	if err != nil {
-		return []string{fmt.Sprintf("ERROR: file %s not marshalling into a TaskRun list: %s\n", fileName, err.Error())}, nil, nil, false
-	}
-
-	for _, tr := range trList.Items {
-		if ignoreTaskRun(&tr, prFilter) {
-			continue
-		}
-
-		processTaskRun(&tr)
-	}
-	sort.Float64s(trDurations)
-	retS := []string{}
-	retF := []float64{}
-	retI := []int{}
-	for _, duration := range trDurations {
-		for key, value := range trToDuration {
-			if value == duration {
-				retS = append(retS, key)
-				retF = append(retF, value)
-				retI = append(retI, determineTRConcurrency(key))
-			}
-		}
-	}
-	return retS, retF, retI, true
-}
-
 func ParseTaskRunList() *cobra.Command {
 	parseTRList := &cobra.Command{
-		Use:   "trlist <file location or directory tree with files> [<options>]",
+		Use:   "trlist [<file location or directory tree with files>] [<options>]",
 		Short: "Parse a list of TaskRun for various statistics",
 		Long:  "Parse a list of TaskRun for various statistics",
 		Example: `
+# Print just the taskruns
+$ tapa trlist <taskrun list json/yaml file or directory with files>
+
+# Print just the steps and the sidecars of the taskruns, keyed as namespace:taskrun/step
+$ tapa trlist <taskrun list json/yaml file or directory with files> --steps
 `,
 		Run: func(cmd *cobra.Command, args []string) {
-			if len(args) == 0 {
-				fmt.Fprintf(os.Stderr, "ERROR: not enough arguments: %s\n", cmd.Use)
+			srcs, err := sources(cmd, args, 1)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
 				return
 			}
-			fileName := args[0]
-			retS, retF, retI, ok := parseTaskRunList(fileName, "")
-			w := os.Stdout
-			if !ok {
-				w = os.Stderr
-				for _, str := range retS {
-					fmt.Fprintf(w, str)
-				}
+			fileName := sourceName(args, 0)
+			analyzer, err := analysis.ParseTaskRunList(srcs[0], filterOptions)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: file %s not marshalling into a TaskRun list: %s\n", fileName, err.Error())
 				return
 			}
-			printList("TaskRun", retS, retF, retI)
+			printer := report.NewPrinter(outputType, os.Stdout)
+			if stepsOnly {
+				printRecordLists(printer, []string{"Step", "Sidecar"}, [][]analysis.Record{analyzer.Steps(), analyzer.Sidecars()})
+				return
+			}
+			printRecords(printer, "TaskRun", analyzer.TaskRuns())
 		},
 	}
+	parseTRList.Flags().BoolVar(&stepsOnly, "steps", stepsOnly,
+		"Only list the steps, and sidecars apart from them, of the taskruns and not the taskruns")
 	return parseTRList
 }
 
 func ParseAllThreeLists() *cobra.Command {
 	allList := &cobra.Command{
-		Use:   "all <pr file location> <tr file location> <pod file location> [<options>]",
+		Use:   "all [<pr file location> <tr file location> <pod file location>] [<options>]",
 		Short: "Parse a list of PipelineRuns, their TaskRuns, and their Pods, for various statistics",
 		Long:  "Parse a list of PipelineRuns, their TaskRuns, and their Pods, for various statistics",
 		Example: `
 `,
 		Run: func(cmd *cobra.Command, args []string) {
-			if len(args) < 1 {
-				fmt.Fprintf(os.Stderr, "ERROR: not enough arguments: %s\n", cmd.Use)
+			srcs, err := sources(cmd, args, 3)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+				return
+			}
+			prFileName, trFileName, podFileName := sourceName(args, 0), sourceName(args, 1), sourceName(args, 2)
+
+			prAnalyzer, trAnalyzer, podAnalyzer, err := analysis.ParseLists(srcs[0], srcs[1], srcs[2], filterOptions)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: problem reading files %s, %s and %s: %s\n", prFileName, trFileName, podFileName, err.Error())
+				return
+			}
+			prRecords := prAnalyzer.PipelineRuns()
+			trRecords := trAnalyzer.TaskRuns()
+			podRecords := podAnalyzer.Pods()
+			printer := report.NewPrinter(outputType, os.Stdout)
+			if len(groupBy) > 0 {
+				printer.PrintGroups("PipelineRun", groupBy, analysis.GroupBy(prRecords, groupBy))
+				printer.PrintGroups("TaskRun", groupBy, analysis.GroupBy(trRecords, groupBy))
+				printer.PrintGroups("Pod", groupBy, analysis.GroupBy(podRecords, groupBy))
+			} else if !summaryOnly {
+				printer.PrintBreakdown(analysis.BreakdownPipelineRuns(prRecords, trRecords, podRecords))
+			}
+			printer.PrintSummary([]string{"PipelineRun", "TaskRun", "Pod"},
+				[]analysis.Summary{analysis.Summarize(prRecords), analysis.Summarize(trRecords), analysis.Summarize(podRecords)})
+			flush(printer)
+		},
+	}
+	return allList
+}
+
+func ParseCriticalPath() *cobra.Command {
+	critPath := &cobra.Command{
+		Use:   "critpath [<pr file location> <tr file location>] [<options>]",
+		Short: "Determine the chain of TaskRuns that bounded the duration of each PipelineRun",
+		Long: "Determine the chain of TaskRuns that bounded the duration of each PipelineRun, from the runAfter and result dependencies\n" +
+			" of its pipeline spec, along with the slack of each PipelineTask and how much of the PipelineRun was spent between TaskRuns.",
+		Example: `
+# Print the critical path of each PipelineRun from separate PipelineRun and TaskRun files
+$ tapa critpath <pipelinerun list json/yaml file> <taskrun list json/yaml file>
+
+# Print the critical path of each PipelineRun from a directory with both PipelineRun and TaskRun files
+$ tapa critpath <directory with files>
+
+# Print the critical path of each PipelineRun from a .tar, .tar.gz or .tgz archive with both PipelineRun and TaskRun files
+$ tapa critpath <archive with files>
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			srcs, err := sources(cmd, args, 2)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
 				return
 			}
-			fileStat, err := os.Stat(args[0])
+			prFileName, trFileName := sourceName(args, 0), sourceName(args, 1)
+
+			paths, err := analysis.ParseCriticalPaths(srcs[0], srcs[1], filterOptions)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "ERROR: could not analyze file %s: %s\n", args[0], err.Error())
+				fmt.Fprintf(os.Stderr, "ERROR: problem reading files %s and %s: %s\n", prFileName, trFileName, err.Error())
 				return
 			}
-			if len(args) < 3 && !fileStat.IsDir() {
-				fmt.Fprintf(os.Stderr, "ERROR: not enough arguments: %s\n", cmd.Use)
+			printer := report.NewPrinter(outputType, os.Stdout)
+			printer.PrintCriticalPaths(paths)
+			flush(printer)
+		},
+	}
+	return critPath
+}
+
+func ClusterFailures() *cobra.Command {
+	failures := &cobra.Command{
+		Use:   "failures [<file location or directory tree with files>] [<options>]",
+		Short: "Cluster the failures of PipelineRuns by reason, exit code and message",
+		Long: "Cluster the failed steps of the failed TaskRuns of failed PipelineRuns by reason, exit code and message, with the names,\n" +
+			" numbers and hashes in messages normalized away, and list how often and when each cluster occurred.",
+		Example: `
+# Print the clusters of failures from a directory with pipelinerun and taskrun files
+$ tapa failures <directory with pipelinerun and taskrun json/yaml files>
+
+# Print up to 10 example pipelineruns for each cluster
+$ tapa failures <directory with pipelinerun and taskrun json/yaml files> --examples 10
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			srcs, err := sources(cmd, args, 1)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
 				return
 			}
-			var prFileName, trFileName, podFileName string
-			if !fileStat.IsDir() {
-				prFileName = args[0]
-				trFileName = args[1]
-				podFileName = args[2]
-			} else {
-				prFileName, trFileName, podFileName = args[0], args[0], args[0]
+			fileName := sourceName(args, 0)
+			failures, err := analysis.ParseFailures(srcs[0], filterOptions, 0)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: problem reading file %s: %s\n", fileName, err.Error())
+				return
 			}
+			printer := report.NewPrinter(outputType, os.Stdout)
+			printer.PrintFailureClusters(analysis.ClusterFailures(failures, examples))
+			flush(printer)
+		},
+	}
+	failures.Flags().IntVar(&examples, "examples", examples, "The number of example pipelineruns to list for each cluster")
+	return failures
+}
 
-			retS1, retF1, retI1, ok1 := parsePipelineRunList(prFileName, "")
-			if !ok1 {
-				for _, s := range retS1 {
-					fmt.Fprintf(os.Stderr, s)
-				}
+func Watch() *cobra.Command {
+	watchCmd := &cobra.Command{
+		Use:   "watch [<options>]",
+		Short: "Watch the PipelineRuns, TaskRuns and Pods of a cluster and periodically print statistics as they complete",
+		Long: "Watch the PipelineRuns, TaskRuns and Pods of a cluster, accumulate their timings as they complete, and periodically print\n" +
+			" the rolling statistics, until interrupted.  With a state file the accumulated timings survive restarts.",
+		Example: `
+# Print the statistics of the namespace of a load test every 5 minutes, keeping them across restarts
+$ tapa watch --namespace <namespace> --interval 5m --state-file tapa-state.json
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cluster, err := load.NewCluster(kubeconfig, kubeContext, namespace)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: could not connect to the cluster: %s\n", err.Error())
 				return
 			}
-			retS2, retF2, retI2, ok2 := parseTaskRunList(trFileName, "")
-			if !ok2 {
-				for _, s := range retS2 {
-					fmt.Fprintf(os.Stderr, s)
-				}
+			watcher, err := watch.NewWatcher(filterOptions, stateFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: problem reading state file %s: %s\n", stateFile, err.Error())
 				return
 			}
-			retS3, retF3, retI3, ok3 := parsePodList(podFileName, "")
-			if !ok3 {
-				for _, s := range retS3 {
-					fmt.Fprintf(os.Stderr, s)
-				}
+			servesV1, err := cluster.ServesV1()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: could not discover the Tekton API versions of the cluster: %s\n", err.Error())
 				return
 			}
-			printHeader("PipelineRun", "Duration", "Concurrency", "TaskRunsDuration", "TaskRunsDelta", "TaskRunsPercentage", "TaskRunsMaxConcurrency", "PodsDuration", "PodsDelta", "PodsPercentage", "PodsMaxConcurrency")
-			for i, prkey := range retS1 {
-				prDuration := retF1[i]
-				prConcurency := retI1[i]
-
-				totalTRDuration := float64(0)
-				maxTRConcurrency := 0
-				for ii, trKey := range retS2 {
-					if !strings.HasPrefix(trKey, prkey) {
-						continue
-					}
-					totalTRDuration = totalTRDuration + retF2[ii]
-					if retI2[ii] > maxTRConcurrency {
-						maxTRConcurrency = retI2[ii]
-					}
-				}
-				totalPodDuration := float64(0)
-				maxPodConcurrency := 0
-				for iii, podKey := range retS3 {
-					if !strings.HasPrefix(podKey, prkey) {
-						continue
-					}
-					totalPodDuration = totalPodDuration + retF3[iii]
-					maxPodConcurrency = maxPodConcurrency + retI3[iii]
-					if retI3[iii] > maxPodConcurrency {
-						maxPodConcurrency = retI3[iii]
-					}
+			watcher.V1beta1 = !servesV1
+			stop := make(chan struct{})
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				<-signals
+				close(stop)
+			}()
+			tektonFactory, kubeFactory := watch.NewFactories(cluster)
+			err = watcher.Run(tektonFactory, kubeFactory, interval, stop, func(w *watch.Watcher) {
+				prRecords, trRecords, podRecords := w.Records()
+				printer := report.NewPrinter(outputType, os.Stdout)
+				if len(groupBy) > 0 {
+					printer.PrintGroups("PipelineRun", groupBy, analysis.GroupBy(prRecords, groupBy))
+					printer.PrintGroups("TaskRun", groupBy, analysis.GroupBy(trRecords, groupBy))
+					printer.PrintGroups("Pod", groupBy, analysis.GroupBy(podRecords, groupBy))
 				}
-				printLine("PipelineRun %s\t\t took %v seconds with pr concurrency %d with taskruns %v seconds delta %v percent %f taskrun max concurrency %d pods %v seconds delta %v percent %f pod max concurrency %d\n",
-					prkey,
-					prDuration,
-					prConcurency,
-					totalTRDuration,
-					prDuration-totalTRDuration,
-					totalTRDuration/prDuration,
-					maxTRConcurrency,
-					totalPodDuration,
-					prDuration-totalPodDuration,
-					totalPodDuration/prDuration,
-					maxPodConcurrency)
+				printer.PrintSummary([]string{"PipelineRun", "TaskRun", "Pod"},
+					[]analysis.Summary{analysis.Summarize(prRecords), analysis.Summarize(trRecords), analysis.Summarize(podRecords)})
+				flush(printer)
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: problem saving state file %s: %s\n", stateFile, err.Error())
 			}
-
 		},
 	}
-	return allList
+	watchCmd.Flags().DurationVar(&interval, "interval", interval, "How often to print the rolling statistics")
+	watchCmd.Flags().StringVar(&stateFile, "state-file", stateFile,
+		"The file the accumulated timings are saved to, and resumed from when it exists")
+	return watchCmd
 }
 
-func printHeader(headers ...string) {
-	out := ""
-	switch outputType {
-	case OutputTypeCsv:
-		for _, h := range headers {
-			if len(out) > 0 {
-				out += fmt.Sprintf(";%s", h)
-			} else {
-				out = h
+// sources returns a Source for each of the count kinds of objects a command reads: Tekton Results when its url is
+// given, the live cluster when no file is given, the single directory or archive given for every kind, or else one
+// file per kind
+func sources(cmd *cobra.Command, args []string, count int) ([]load.Source, error) {
+	srcs := make([]load.Source, count)
+	if len(resultsURL) > 0 {
+		results := &load.Results{URL: resultsURL, Parent: namespace, Token: resultsToken}
+		for i := range srcs {
+			srcs[i] = results
+		}
+		return srcs, nil
+	}
+	if len(args) == 0 {
+		cluster, err := load.NewCluster(kubeconfig, kubeContext, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to the cluster: %s", err.Error())
+		}
+		for i := range srcs {
+			srcs[i] = cluster
+		}
+		return srcs, nil
+	}
+	if count > 1 && len(args) == 1 {
+		fileStat, err := os.Stat(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("could not analyze file %s: %s", args[0], err.Error())
+		}
+		// a directory or a tar archive may hold several kinds of objects, which are all read in a single walk
+		if fileStat.IsDir() || load.IsTar(args[0]) {
+			files := load.NewFiles(args[0], workers)
+			for i := range srcs {
+				srcs[i] = files
 			}
+			return srcs, nil
 		}
-		fmt.Fprintln(os.Stdout, out)
-	default:
-		// text output does not have a header, do not print anything
 	}
+	if len(args) < count {
+		return nil, fmt.Errorf("not enough arguments: %s", cmd.Use)
+	}
+	for i := range srcs {
+		srcs[i] = load.NewFiles(args[i], workers)
+	}
+	return srcs, nil
 }
 
-func printLine(format string, values ...any) {
-	w := os.Stdout
-	out := ""
-	switch outputType {
-	case OutputTypeCsv:
-		for _, v := range values {
-			if len(out) > 0 {
-				out += fmt.Sprintf(";%v", v)
-			} else {
-				out = fmt.Sprintf("%v", v)
-			}
+// sourceName returns the file argument a Source of sources was created from, for error messages
+func sourceName(args []string, index int) string {
+	switch {
+	case len(resultsURL) > 0:
+		return "from " + resultsURL
+	case len(args) == 0:
+		return "from the cluster"
+	case index < len(args):
+		return args[index]
+	}
+	return args[0]
+}
+
+// parseFilterOptions fills in filterOptions from the filter flags, with relative times taken before now
+func parseFilterOptions(now time.Time) error {
+	var err error
+	if len(since) > 0 {
+		if filterOptions.Since, err = filter.ParseTime(since, now); err != nil {
+			return fmt.Errorf("invalid value for since: %s", err.Error())
+		}
+	}
+	if len(until) > 0 {
+		if filterOptions.Until, err = filter.ParseTime(until, now); err != nil {
+			return fmt.Errorf("invalid value for until: %s", err.Error())
+		}
+	}
+	filterOptions.Namespace = namespace
+	filterOptions.Statuses = statuses
+	if len(name) > 0 {
+		if filterOptions.Name, err = filter.ParseName(name); err != nil {
+			return fmt.Errorf("invalid value for name: %s", err.Error())
+		}
+	}
+	if len(selector) > 0 {
+		if filterOptions.Selector, err = labels.Parse(selector); err != nil {
+			return fmt.Errorf("invalid value for selector: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// printRecords prints each record, unless only the summary was requested, followed by the summary of the records
+func printRecords(printer *report.Printer, resource string, records []analysis.Record) {
+	printRecordLists(printer, []string{resource}, [][]analysis.Record{records})
+}
+
+// printRecordLists prints the records, or the groups of records when grouping, of each resource, unless only the
+// summary was requested, followed by the summary of the records of each resource
+func printRecordLists(printer *report.Printer, resources []string, records [][]analysis.Record) {
+	summaries := []analysis.Summary{}
+	for i, resource := range resources {
+		if len(groupBy) > 0 {
+			printer.PrintGroups(resource, groupBy, analysis.GroupBy(records[i], groupBy))
+		} else if !summaryOnly {
+			printer.PrintList(resource, records[i])
 		}
-		fmt.Fprintln(w, out)
-	default:
-		fmt.Fprintf(w, format, values...)
+		summaries = append(summaries, analysis.Summarize(records[i]))
 	}
+	printer.PrintSummary(resources, summaries)
+	flush(printer)
 }
 
-func printList(resource string, keys []string, durations []float64, concurencies []int) {
-	printHeader(resource, "Duration", "Concurrency")
-	for i, key := range keys {
-		printLine(fmt.Sprintf("%s %%s\t\ttook %%v seconds concurrency %%d\n", resource), key, durations[i], concurencies[i])
+func flush(printer *report.Printer) {
+	if err := printer.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: problem writing %s output: %s\n", printer.OutputType, err.Error())
 	}
 }
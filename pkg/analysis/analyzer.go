@@ -0,0 +1,364 @@
+// Package analysis computes the durations and concurrency of PipelineRuns, TaskRuns, Pods and containers.
+package analysis
+
+import (
+	"fmt"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/filter"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sidecarContainerPrefix is prepended by Tekton to the name of each sidecar when creating its container
+const sidecarContainerPrefix = "sidecar-"
+
+// Record holds the timing of a single PipelineRun, TaskRun, Pod or container, identified by its namespace:name key
+type Record struct {
+	Key         string    `json:"key"`
+	Namespace   string    `json:"namespace"`
+	Name        string    `json:"name"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Duration    float64   `json:"duration"`
+	Concurrency int       `json:"concurrency"`
+	// PipelineRun and TaskRun name, in the same namespace, the object was created for when known
+	PipelineRun string `json:"pipelineRun,omitempty"`
+	TaskRun     string `json:"taskRun,omitempty"`
+	// InitOverhead is the seconds a Pod spent running its init containers, such as the ones Tekton injects to
+	// prepare its steps
+	InitOverhead float64 `json:"initOverhead,omitempty"`
+	// Status is the reason of the Succeeded condition of PipelineRuns and TaskRuns, the phase or reason of Pods,
+	// and the termination reason of steps and containers
+	Status string `json:"status,omitempty"`
+	// Pipeline and Task the object was created from when known, and the labels of the object, for grouping
+	Pipeline string            `json:"pipeline,omitempty"`
+	Task     string            `json:"task,omitempty"`
+	Labels   map[string]string `json:"-"`
+}
+
+// timings indexes the records of every object of a single resource kind by their key
+type timings struct {
+	records map[string]Record
+}
+
+func newTimings() *timings {
+	return &timings{
+		records: map[string]Record{},
+	}
+}
+
+// add indexes r, filling in its key and duration from its namespace, name, start and end
+func (t *timings) add(r Record) time.Duration {
+	duration := r.End.Sub(r.Start)
+	r.Key = fmt.Sprintf("%s:%s", r.Namespace, r.Name)
+	r.Duration = duration.Seconds()
+	t.records[r.Key] = r
+	return duration
+}
+
+// list returns a record per key, sorted by duration and then by key so objects sharing a duration keep a stable order
+func (t *timings) list() []Record {
+	startTimes := make(map[string]time.Time, len(t.records))
+	endTimes := make(map[string]time.Time, len(t.records))
+	for key, r := range t.records {
+		startTimes[key] = r.Start
+		endTimes[key] = r.End
+	}
+	concurrencies := Concurrencies(startTimes, endTimes)
+	records := make([]Record, 0, len(t.records))
+	for key, r := range t.records {
+		r.Concurrency = concurrencies[key]
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Duration != records[j].Duration {
+			return records[i].Duration < records[j].Duration
+		}
+		return records[i].Key < records[j].Key
+	})
+	return records
+}
+
+// Analyzer owns the timing indexes for PipelineRuns, TaskRuns, steps, Pods, containers and sidecars of a single analysis, so
+// that several analyses can run in the same process without sharing results
+type Analyzer struct {
+	pipelineRuns *timings
+	taskRuns     *timings
+	steps        *timings
+	pods         *timings
+	containers   *timings
+	sidecars     *timings
+	latencies    map[string]PodLatency
+	imagePulls   map[string][]imagePullEvent
+}
+
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{
+		pipelineRuns: newTimings(),
+		taskRuns:     newTimings(),
+		steps:        newTimings(),
+		pods:         newTimings(),
+		containers:   newTimings(),
+		sidecars:     newTimings(),
+		latencies:    map[string]PodLatency{},
+		imagePulls:   map[string][]imagePullEvent{},
+	}
+}
+
+// PipelineRuns returns a record for each PipelineRun processed so far, sorted by duration
+func (a *Analyzer) PipelineRuns() []Record {
+	return a.pipelineRuns.list()
+}
+
+// TaskRuns returns a record for each TaskRun processed so far, sorted by duration
+func (a *Analyzer) TaskRuns() []Record {
+	return a.taskRuns.list()
+}
+
+// Steps returns a record for each TaskRun step processed so far, sorted by duration
+func (a *Analyzer) Steps() []Record {
+	return a.steps.list()
+}
+
+// Pods returns a record for each Pod processed so far, sorted by duration
+func (a *Analyzer) Pods() []Record {
+	return a.pods.list()
+}
+
+// Containers returns a record for each container processed so far, sorted by duration
+func (a *Analyzer) Containers() []Record {
+	return a.containers.list()
+}
+
+// Sidecars returns a record for each sidecar processed so far, from either TaskRuns or Pods, sorted by duration
+func (a *Analyzer) Sidecars() []Record {
+	return a.sidecars.list()
+}
+
+// ProcessPipelineRun records the start, completion and duration of a completed PipelineRun
+func (a *Analyzer) ProcessPipelineRun(pr *v1beta1.PipelineRun) time.Duration {
+	pipelineRef := ""
+	if pr.Spec.PipelineRef != nil {
+		pipelineRef = pr.Spec.PipelineRef.Name
+	}
+	return a.pipelineRuns.add(Record{
+		Namespace: pr.Namespace,
+		Name:      pr.Name,
+		Start:     pr.Status.StartTime.Time,
+		End:       pr.Status.CompletionTime.Time,
+		Status:    filter.PipelineRunStatus(pr),
+		Pipeline:  refName(pr.ObjectMeta, pipeline.PipelineLabelKey, pipelineRef),
+		Labels:    pr.Labels,
+	})
+}
+
+// ProcessTaskRun records the start, completion and duration of a completed TaskRun
+func (a *Analyzer) ProcessTaskRun(tr *v1beta1.TaskRun) time.Duration {
+	return a.taskRuns.add(Record{
+		Namespace:   tr.Namespace,
+		Name:        tr.Name,
+		Start:       tr.Status.StartTime.Time,
+		End:         tr.Status.CompletionTime.Time,
+		PipelineRun: ownerName(tr.ObjectMeta, pipeline.PipelineRunLabelKey, "PipelineRun"),
+		Status:      filter.TaskRunStatus(tr),
+		Pipeline:    refName(tr.ObjectMeta, pipeline.PipelineLabelKey, ""),
+		Task:        refName(tr.ObjectMeta, pipeline.TaskLabelKey, taskRef(tr)),
+		Labels:      tr.Labels,
+	})
+}
+
+// ProcessSteps records each terminated step of a TaskRun from its status, keyed as namespace:taskrun/step
+func (a *Analyzer) ProcessSteps(tr *v1beta1.TaskRun) []time.Duration {
+	durations := []time.Duration{}
+	for _, step := range tr.Status.Steps {
+		terminated := step.Terminated
+		if terminated == nil {
+			continue
+		}
+		durations = append(durations, a.steps.add(Record{
+			Namespace:   tr.Namespace,
+			Name:        fmt.Sprintf("%s/%s", tr.Name, step.Name),
+			Start:       terminated.StartedAt.Time,
+			End:         terminated.FinishedAt.Time,
+			PipelineRun: ownerName(tr.ObjectMeta, pipeline.PipelineRunLabelKey, "PipelineRun"),
+			TaskRun:     tr.Name,
+			Status:      terminated.Reason,
+			Pipeline:    refName(tr.ObjectMeta, pipeline.PipelineLabelKey, ""),
+			Task:        refName(tr.ObjectMeta, pipeline.TaskLabelKey, taskRef(tr)),
+			Labels:      tr.Labels,
+		}))
+	}
+	return durations
+}
+
+// ProcessPod records a Pod from its start time to the termination of its last container, along with the time its
+// init containers ran
+func (a *Analyzer) ProcessPod(pod *corev1.Pod) time.Duration {
+	initOverhead := float64(0)
+	for _, status := range pod.Status.InitContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil {
+			initOverhead += terminated.FinishedAt.Sub(terminated.StartedAt.Time).Seconds()
+		}
+	}
+	var terimnatedTime time.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if terminated != nil {
+			if terminated.FinishedAt.Time.After(terimnatedTime) {
+				terimnatedTime = terminated.FinishedAt.Time
+			}
+		}
+	}
+	return a.pods.add(Record{
+		Namespace:    pod.Namespace,
+		Name:         pod.Name,
+		Start:        pod.Status.StartTime.Time,
+		End:          terimnatedTime,
+		PipelineRun:  ownerName(pod.ObjectMeta, pipeline.PipelineRunLabelKey, "PipelineRun"),
+		TaskRun:      ownerName(pod.ObjectMeta, pipeline.TaskRunLabelKey, "TaskRun"),
+		InitOverhead: initOverhead,
+		Status:       filter.PodStatus(pod),
+		Pipeline:     refName(pod.ObjectMeta, pipeline.PipelineLabelKey, ""),
+		Task:         refName(pod.ObjectMeta, pipeline.TaskLabelKey, ""),
+		Labels:       pod.Labels,
+	})
+}
+
+// ProcessContainers records each terminated init container and container of a Pod; init containers already run one
+// after the other, while step containers are treated as running sequentially in spec order.  Tekton sidecars run
+// alongside the steps for most of the life of the Pod, so they are recorded as sidecars from their own start and
+// finish and skipped when chaining the steps.
+func (a *Analyzer) ProcessContainers(pod *corev1.Pod) []time.Duration {
+	durations := []time.Duration{}
+	for _, cstatus := range pod.Status.InitContainerStatuses {
+		terminated := cstatus.State.Terminated
+		if terminated == nil {
+			continue
+		}
+		durations = append(durations, a.containers.add(Record{
+			Namespace:   pod.Namespace,
+			Name:        fmt.Sprintf("%s-%s", pod.Name, cstatus.Name),
+			Start:       terminated.StartedAt.Time,
+			End:         terminated.FinishedAt.Time,
+			PipelineRun: ownerName(pod.ObjectMeta, pipeline.PipelineRunLabelKey, "PipelineRun"),
+			TaskRun:     ownerName(pod.ObjectMeta, pipeline.TaskRunLabelKey, "TaskRun"),
+			Status:      terminated.Reason,
+			Pipeline:    refName(pod.ObjectMeta, pipeline.PipelineLabelKey, ""),
+			Task:        refName(pod.ObjectMeta, pipeline.TaskLabelKey, ""),
+			Labels:      pod.Labels,
+		}))
+	}
+	specNameToIndex := map[string]int{}
+	statusNameToIndex := map[string]int{}
+	for index, container := range pod.Spec.Containers {
+		specNameToIndex[container.Name] = index
+	}
+	for index, cstatus := range pod.Status.ContainerStatuses {
+		statusNameToIndex[cstatus.Name] = index
+	}
+	for _, cstatus := range pod.Status.ContainerStatuses {
+		terminated := cstatus.State.Terminated
+		if terminated == nil {
+			continue
+		}
+		record := Record{
+			Namespace:   pod.Namespace,
+			Name:        fmt.Sprintf("%s-%s", pod.Name, cstatus.Name),
+			Start:       terminated.StartedAt.Time,
+			End:         terminated.FinishedAt.Time,
+			PipelineRun: ownerName(pod.ObjectMeta, pipeline.PipelineRunLabelKey, "PipelineRun"),
+			TaskRun:     ownerName(pod.ObjectMeta, pipeline.TaskRunLabelKey, "TaskRun"),
+			Status:      terminated.Reason,
+			Pipeline:    refName(pod.ObjectMeta, pipeline.PipelineLabelKey, ""),
+			Task:        refName(pod.ObjectMeta, pipeline.TaskLabelKey, ""),
+			Labels:      pod.Labels,
+		}
+		if isSidecar(cstatus.Name) {
+			durations = append(durations, a.sidecars.add(record))
+			continue
+		}
+		// containers are created started concurrently, but k8s/linux "pauses" then "resumes" per spec order
+		// so we take that finish time of the prior step container if not the first one
+		for specIndex := specNameToIndex[cstatus.Name] - 1; specIndex >= 0; specIndex-- {
+			priorContainerName := pod.Spec.Containers[specIndex].Name
+			if isSidecar(priorContainerName) {
+				continue
+			}
+			priorContainerStatusIndex, ok := statusNameToIndex[priorContainerName]
+			if !ok {
+				break
+			}
+			priorContainerStatus := pod.Status.ContainerStatuses[priorContainerStatusIndex]
+			if priorContainerStatus.State.Terminated != nil {
+				record.Start = priorContainerStatus.State.Terminated.FinishedAt.Time
+			}
+			break
+		}
+		durations = append(durations, a.containers.add(record))
+	}
+	return durations
+}
+
+// ProcessSidecars records each terminated sidecar of a TaskRun from its status, keyed as namespace:taskrun/sidecar
+func (a *Analyzer) ProcessSidecars(tr *v1beta1.TaskRun) []time.Duration {
+	durations := []time.Duration{}
+	for _, sidecar := range tr.Status.Sidecars {
+		terminated := sidecar.Terminated
+		if terminated == nil {
+			continue
+		}
+		durations = append(durations, a.sidecars.add(Record{
+			Namespace:   tr.Namespace,
+			Name:        fmt.Sprintf("%s/%s", tr.Name, sidecar.Name),
+			Start:       terminated.StartedAt.Time,
+			End:         terminated.FinishedAt.Time,
+			PipelineRun: ownerName(tr.ObjectMeta, pipeline.PipelineRunLabelKey, "PipelineRun"),
+			TaskRun:     tr.Name,
+			Status:      terminated.Reason,
+			Pipeline:    refName(tr.ObjectMeta, pipeline.PipelineLabelKey, ""),
+			Task:        refName(tr.ObjectMeta, pipeline.TaskLabelKey, taskRef(tr)),
+			Labels:      tr.Labels,
+		}))
+	}
+	return durations
+}
+
+// isSidecar returns true for the names Tekton gives the containers of the sidecars of a Task
+func isSidecar(containerName string) bool {
+	return strings.HasPrefix(containerName, sidecarContainerPrefix)
+}
+
+// ownerName returns the name of the object of the given kind that meta was created for, taken from the Tekton
+// label when set and otherwise from an owner reference
+func ownerName(meta metav1.ObjectMeta, label, kind string) string {
+	if name := meta.Labels[label]; len(name) > 0 {
+		return name
+	}
+	for _, ref := range meta.OwnerReferences {
+		if ref.Kind == kind {
+			return ref.Name
+		}
+	}
+	return ""
+}
+
+// refName returns the name of the Pipeline or Task meta was created from, taken from the Tekton label when set and
+// otherwise from the name referenced by the spec
+func refName(meta metav1.ObjectMeta, label, specRef string) string {
+	if name := meta.Labels[label]; len(name) > 0 {
+		return name
+	}
+	return specRef
+}
+
+// taskRef returns the name of the Task referenced by the spec of tr, if any
+func taskRef(tr *v1beta1.TaskRun) string {
+	if tr.Spec.TaskRef != nil {
+		return tr.Spec.TaskRef.Name
+	}
+	return ""
+}
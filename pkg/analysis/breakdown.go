@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PipelineRunBreakdown compares the duration of a PipelineRun with the total duration of its TaskRuns and Pods, and
+// of the init containers of those Pods
+type PipelineRunBreakdown struct {
+	Record
+	TaskRunsDuration       float64 `json:"taskRunsDuration"`
+	TaskRunsDelta          float64 `json:"taskRunsDelta"`
+	TaskRunsPercentage     float64 `json:"taskRunsPercentage"`
+	TaskRunsMaxConcurrency int     `json:"taskRunsMaxConcurrency"`
+	PodsDuration           float64 `json:"podsDuration"`
+	PodsDelta              float64 `json:"podsDelta"`
+	PodsPercentage         float64 `json:"podsPercentage"`
+	PodsMaxConcurrency     int     `json:"podsMaxConcurrency"`
+	PodsInitOverhead       float64 `json:"podsInitOverhead"`
+}
+
+// BreakdownPipelineRuns returns a PipelineRunBreakdown for each PipelineRun record, in the same order.  TaskRuns
+// and Pods are matched with the PipelineRun they were created for, directly or for Pods through their TaskRun, and
+// only when neither is known fall back to matching when their key starts with the PipelineRun key.
+func BreakdownPipelineRuns(prRecords, trRecords, podRecords []Record) []PipelineRunBreakdown {
+	trsByPR := map[string][]Record{}
+	trToPR := map[string]string{}
+	unownedTRs := []Record{}
+	for _, trRecord := range trRecords {
+		if len(trRecord.PipelineRun) == 0 {
+			unownedTRs = append(unownedTRs, trRecord)
+			continue
+		}
+		prKey := fmt.Sprintf("%s:%s", trRecord.Namespace, trRecord.PipelineRun)
+		trsByPR[prKey] = append(trsByPR[prKey], trRecord)
+		trToPR[trRecord.Key] = prKey
+	}
+	podsByPR := map[string][]Record{}
+	unownedPods := []Record{}
+	for _, podRecord := range podRecords {
+		prKey := ""
+		if len(podRecord.PipelineRun) > 0 {
+			prKey = fmt.Sprintf("%s:%s", podRecord.Namespace, podRecord.PipelineRun)
+		} else if len(podRecord.TaskRun) > 0 {
+			prKey = trToPR[fmt.Sprintf("%s:%s", podRecord.Namespace, podRecord.TaskRun)]
+		}
+		if len(prKey) == 0 {
+			unownedPods = append(unownedPods, podRecord)
+			continue
+		}
+		podsByPR[prKey] = append(podsByPR[prKey], podRecord)
+	}
+
+	breakdowns := []PipelineRunBreakdown{}
+	for _, prRecord := range prRecords {
+		prkey := prRecord.Key
+		prDuration := prRecord.Duration
+
+		totalTRDuration := float64(0)
+		maxTRConcurrency := 0
+		for _, trRecord := range withPrefixFallback(trsByPR[prkey], unownedTRs, prkey) {
+			totalTRDuration = totalTRDuration + trRecord.Duration
+			if trRecord.Concurrency > maxTRConcurrency {
+				maxTRConcurrency = trRecord.Concurrency
+			}
+		}
+		totalPodDuration := float64(0)
+		maxPodConcurrency := 0
+		podInitOverhead := float64(0)
+		for _, podRecord := range withPrefixFallback(podsByPR[prkey], unownedPods, prkey) {
+			totalPodDuration = totalPodDuration + podRecord.Duration
+			podInitOverhead = podInitOverhead + podRecord.InitOverhead
+			maxPodConcurrency = maxPodConcurrency + podRecord.Concurrency
+			if podRecord.Concurrency > maxPodConcurrency {
+				maxPodConcurrency = podRecord.Concurrency
+			}
+		}
+		breakdown := PipelineRunBreakdown{
+			Record:                 prRecord,
+			TaskRunsDuration:       totalTRDuration,
+			TaskRunsDelta:          prDuration - totalTRDuration,
+			TaskRunsMaxConcurrency: maxTRConcurrency,
+			PodsDuration:           totalPodDuration,
+			PodsDelta:              prDuration - totalPodDuration,
+			PodsMaxConcurrency:     maxPodConcurrency,
+			PodsInitOverhead:       podInitOverhead,
+		}
+		// a zero length PipelineRun has no meaningful percentage, and json cannot encode the NaN division yields
+		if prDuration != 0 {
+			breakdown.TaskRunsPercentage = totalTRDuration / prDuration
+			breakdown.PodsPercentage = totalPodDuration / prDuration
+		}
+		breakdowns = append(breakdowns, breakdown)
+	}
+	return breakdowns
+}
+
+// withPrefixFallback adds to the records owned by a PipelineRun the unowned records whose key starts with its key
+func withPrefixFallback(owned, unowned []Record, prKey string) []Record {
+	records := append([]Record{}, owned...)
+	for _, r := range unowned {
+		if strings.HasPrefix(r.Key, prKey) {
+			records = append(records, r)
+		}
+	}
+	return records
+}
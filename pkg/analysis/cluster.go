@@ -0,0 +1,166 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxClusterMessage is the length normalized messages are truncated to, so long outputs of a step still cluster
+const maxClusterMessage = 200
+
+var (
+	hexPattern        = regexp.MustCompile(`\b[0-9a-fA-F-]{8,}\b`)
+	numberPattern     = regexp.MustCompile(`[0-9]+`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// FailureCluster is a set of failures sharing the same reason, exit code and normalized message
+type FailureCluster struct {
+	Reason   string `json:"reason"`
+	ExitCode int32  `json:"exitCode"`
+	Message  string `json:"message"`
+	Count    int    `json:"count"`
+	// Examples are the keys of the first few PipelineRuns with the failure
+	Examples        []string  `json:"examples"`
+	FirstOccurrence time.Time `json:"firstOccurrence"`
+	LastOccurrence  time.Time `json:"lastOccurrence"`
+}
+
+// ClusterFailures groups the failed steps of failures, or the failed TaskRuns without a failed step, or the
+// PipelineRuns without a failed TaskRun, by reason, exit code and message, after removing from the message the
+// names of the objects involved along with any numbers and hashes.  A failure occurs when its PipelineRun
+// completed, and at most examples PipelineRuns are kept per cluster.  Clusters are sorted by descending count.
+func ClusterFailures(failures []PipelineRunFailure, examples int) []FailureCluster {
+	clusters := map[string]*FailureCluster{}
+	add := func(f PipelineRunFailure, reason string, exitCode int32, message string, names ...string) {
+		message = normalizeMessage(message, append(names, f.Namespace, f.Name)...)
+		key := fmt.Sprintf("%s\x00%d\x00%s", reason, exitCode, message)
+		cluster, ok := clusters[key]
+		if !ok {
+			cluster = &FailureCluster{
+				Reason:          reason,
+				ExitCode:        exitCode,
+				Message:         message,
+				Examples:        []string{},
+				FirstOccurrence: f.End,
+				LastOccurrence:  f.End,
+			}
+			clusters[key] = cluster
+		}
+		cluster.Count++
+		if f.End.Before(cluster.FirstOccurrence) {
+			cluster.FirstOccurrence = f.End
+		}
+		if f.End.After(cluster.LastOccurrence) {
+			cluster.LastOccurrence = f.End
+		}
+		for _, example := range cluster.Examples {
+			if example == f.Key {
+				return
+			}
+		}
+		if len(cluster.Examples) < examples {
+			cluster.Examples = append(cluster.Examples, f.Key)
+		}
+	}
+	for _, f := range failures {
+		if len(f.TaskRuns) == 0 {
+			add(f, f.Status, 0, f.Message)
+		}
+		for _, tr := range f.TaskRuns {
+			if len(tr.Steps) == 0 {
+				add(f, tr.Status, 0, tr.Message, tr.Name, tr.Pod)
+			}
+			for _, step := range tr.Steps {
+				add(f, step.Reason, step.ExitCode, step.Message, tr.Name, tr.Pod)
+			}
+		}
+	}
+
+	sorted := make([]FailureCluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		sorted = append(sorted, *cluster)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		if sorted[i].Reason != sorted[j].Reason {
+			return sorted[i].Reason < sorted[j].Reason
+		}
+		if sorted[i].ExitCode != sorted[j].ExitCode {
+			return sorted[i].ExitCode < sorted[j].ExitCode
+		}
+		return sorted[i].Message < sorted[j].Message
+	})
+	return sorted
+}
+
+// normalizeMessage replaces names, then hashes and numbers in message with placeholders, collapses its whitespace and
+// truncates it to maxClusterMessage bytes, without splitting a character
+func normalizeMessage(message string, names ...string) string {
+	message = replaceNames(message, names)
+	message = hexPattern.ReplaceAllStringFunc(message, func(match string) string {
+		// only hashes and uuids, words that happen to be spelled with hex letters are left alone
+		if numberPattern.MatchString(match) {
+			return "<hash>"
+		}
+		return match
+	})
+	message = numberPattern.ReplaceAllString(message, "<n>")
+	message = strings.TrimSpace(whitespacePattern.ReplaceAllString(message, " "))
+	if len(message) > maxClusterMessage {
+		end := maxClusterMessage
+		for end > 0 && !utf8.RuneStart(message[end]) {
+			end--
+		}
+		message = message[:end]
+	}
+	return message
+}
+
+// replaceNames replaces the occurrences of names in message that are whole names, neither preceded nor followed by a
+// letter, digit or dash, with a placeholder, so that a name which is also part of a word leaves the word alone.  The
+// longest name matching at a position wins, and placeholders are never matched again.
+func replaceNames(message string, names []string) string {
+	sorted := []string{}
+	for _, name := range names {
+		if len(name) > 0 {
+			sorted = append(sorted, name)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	replaced := strings.Builder{}
+	for i := 0; i < len(message); {
+		name := ""
+		if i == 0 || !isNameByte(message[i-1]) {
+			for _, n := range sorted {
+				end := i + len(n)
+				if strings.HasPrefix(message[i:], n) && (end == len(message) || !isNameByte(message[end])) {
+					name = n
+					break
+				}
+			}
+		}
+		if len(name) > 0 {
+			replaced.WriteString("<name>")
+			i += len(name)
+			continue
+		}
+		replaced.WriteByte(message[i])
+		i++
+	}
+	return replaced.String()
+}
+
+// isNameByte returns true for the bytes that may continue the name of an object, which the names of Kubernetes
+// objects are mostly made of
+func isNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-'
+}
@@ -0,0 +1,162 @@
+package analysis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeMessage(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		message  string
+		names    []string
+		expected string
+	}{
+		{
+			name:     "names",
+			message:  "pod build-1-fetch-pod of build-1 failed",
+			names:    []string{"build-1", "build-1-fetch-pod"},
+			expected: "pod <name> of <name> failed",
+		},
+		{
+			name:     "name within a word",
+			message:  "image registry/app:latest not found",
+			names:    []string{"test"},
+			expected: "image registry/app:latest not found",
+		},
+		{
+			name:     "name followed by a dash",
+			message:  "test-runner in test failed",
+			names:    []string{"test"},
+			expected: "test-runner in <name> failed",
+		},
+		{
+			name:     "name within a placeholder",
+			message:  "build-1 name",
+			names:    []string{"build-1", "name"},
+			expected: "<name> <name>",
+		},
+		{
+			name:     "hashes and hex looking words",
+			message:  "digest 3f4a9c0b1d2e of uid 123e4567-e89b-12d3-a456-426614174000 is not deadbeefcafe",
+			expected: "digest <hash> of uid <hash> is not deadbeefcafe",
+		},
+		{
+			name:     "numbers",
+			message:  "exit code 137 after 42s",
+			expected: "exit code <n> after <n>s",
+		},
+		{
+			name:     "whitespace",
+			message:  "  line one\n\tline   two  ",
+			expected: "line one line two",
+		},
+		{
+			name:     "truncation",
+			message:  strings.Repeat("x", maxClusterMessage+50),
+			expected: strings.Repeat("x", maxClusterMessage),
+		},
+		{
+			name:     "truncation within a character",
+			message:  strings.Repeat("x", maxClusterMessage-1) + "éé",
+			expected: strings.Repeat("x", maxClusterMessage-1),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if message := normalizeMessage(test.message, test.names...); message != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, message)
+			}
+		})
+	}
+}
+
+func TestClusterFailures(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stepFailure := func(name string, end time.Duration, steps ...StepFailure) PipelineRunFailure {
+		return PipelineRunFailure{
+			Record: Record{Key: "ns:" + name, Namespace: "ns", Name: name, End: base.Add(end), Status: "Failed"},
+			TaskRuns: []TaskRunFailure{{
+				Name:   name + "-build",
+				Pod:    name + "-build-pod",
+				Status: "Failed",
+				Steps:  steps,
+			}},
+		}
+	}
+	retries := func(name string) StepFailure {
+		return StepFailure{Reason: "Error", ExitCode: 1, Message: "step failed in " + name + "-build-pod after 3 retries"}
+	}
+	failures := []PipelineRunFailure{
+		// the same failure twice in one PipelineRun is counted twice but listed as an example once
+		stepFailure("pr-1", 2*time.Hour, retries("pr-1"), retries("pr-1")),
+		stepFailure("pr-2", time.Hour, retries("pr-2")),
+		stepFailure("pr-3", 3*time.Hour, retries("pr-3")),
+		stepFailure("pr-4", time.Hour,
+			StepFailure{Reason: "Error", ExitCode: 2, Message: "killed"},
+			StepFailure{Reason: "Error", ExitCode: 1, Message: "other failure"}),
+		{
+			Record:  Record{Key: "ns:pr-5", Namespace: "ns", Name: "pr-5", End: base, Status: "PipelineRunTimeout"},
+			Message: "pr-5 timed out",
+		},
+		{
+			Record: Record{Key: "ns:pr-6", Namespace: "ns", Name: "pr-6", End: base, Status: "Failed"},
+			TaskRuns: []TaskRunFailure{{
+				Name:    "pr-6-build",
+				Status:  "TaskRunImagePullFailed",
+				Message: "image for pr-6-build not found",
+			}},
+		},
+	}
+
+	expected := []FailureCluster{
+		{
+			Reason:          "Error",
+			ExitCode:        1,
+			Message:         "step failed in <name> after <n> retries",
+			Count:           4,
+			Examples:        []string{"ns:pr-1", "ns:pr-2"},
+			FirstOccurrence: base.Add(time.Hour),
+			LastOccurrence:  base.Add(3 * time.Hour),
+		},
+		{
+			Reason:          "Error",
+			ExitCode:        1,
+			Message:         "other failure",
+			Count:           1,
+			Examples:        []string{"ns:pr-4"},
+			FirstOccurrence: base.Add(time.Hour),
+			LastOccurrence:  base.Add(time.Hour),
+		},
+		{
+			Reason:          "Error",
+			ExitCode:        2,
+			Message:         "killed",
+			Count:           1,
+			Examples:        []string{"ns:pr-4"},
+			FirstOccurrence: base.Add(time.Hour),
+			LastOccurrence:  base.Add(time.Hour),
+		},
+		{
+			Reason:          "PipelineRunTimeout",
+			Message:         "<name> timed out",
+			Count:           1,
+			Examples:        []string{"ns:pr-5"},
+			FirstOccurrence: base,
+			LastOccurrence:  base,
+		},
+		{
+			Reason:          "TaskRunImagePullFailed",
+			Message:         "image for <name> not found",
+			Count:           1,
+			Examples:        []string{"ns:pr-6"},
+			FirstOccurrence: base,
+			LastOccurrence:  base,
+		},
+	}
+	clusters := ClusterFailures(failures, 2)
+	if !reflect.DeepEqual(clusters, expected) {
+		t.Errorf("expected the clusters\n%+v\ngot\n%+v", expected, clusters)
+	}
+}
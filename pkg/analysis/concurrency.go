@@ -0,0 +1,94 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+)
+
+// instant is a map friendly form of a time.Time, since equal times can differ in location
+type instant struct {
+	sec  int64
+	nsec int
+}
+
+func instantOf(t time.Time) instant {
+	return instant{sec: t.Unix(), nsec: t.Nanosecond()}
+}
+
+// Concurrencies returns how many of the objects in starts and ends, including itself, overlap with each key, in
+// O(n log n) by binary searching the sorted start and end times instead of comparing each pair of objects.
+//
+// An object overlaps key when it starts before key ends and ends after key starts, so its count is the number of
+// objects started before key ends less those already ended when key starts.  Objects with the same start and end
+// are always counted, which is what keeps zero length objects from only counting themselves.  An object whose end
+// precedes its start, like a Pod without any terminated container, only counts objects identical to it, and is not
+// counted by any other.
+func Concurrencies(starts map[string]time.Time, ends map[string]time.Time) map[string]int {
+	sortedStarts := []time.Time{}
+	sortedEnds := []time.Time{}
+	identical := map[[2]instant]int{}
+	for key, start := range starts {
+		end, _ := ends[key]
+		identical[[2]instant{instantOf(start), instantOf(end)}]++
+		if end.Before(start) {
+			continue
+		}
+		sortedStarts = append(sortedStarts, start)
+		sortedEnds = append(sortedEnds, end)
+	}
+	sort.Slice(sortedStarts, func(i, j int) bool { return sortedStarts[i].Before(sortedStarts[j]) })
+	sort.Slice(sortedEnds, func(i, j int) bool { return sortedEnds[i].Before(sortedEnds[j]) })
+
+	concurrencies := make(map[string]int, len(starts))
+	for key, start := range starts {
+		end, _ := ends[key]
+		same := identical[[2]instant{instantOf(start), instantOf(end)}]
+		if end.Before(start) {
+			concurrencies[key] = same
+			continue
+		}
+		startedBeforeEnd := sort.Search(len(sortedStarts), func(i int) bool { return !sortedStarts[i].Before(end) })
+		endedByStart := sort.Search(len(sortedEnds), func(i int) bool { return sortedEnds[i].After(start) })
+		if start.Equal(end) {
+			// the identical zero length objects, key included, are among those ended by start but never started
+			// before end, so they are added back before being counted
+			concurrencies[key] = startedBeforeEnd - endedByStart + 2*same
+			continue
+		}
+		concurrencies[key] = startedBeforeEnd - endedByStart
+	}
+	return concurrencies
+}
+
+// PeakConcurrency returns the most records running at the same instant over the whole window they cover
+func PeakConcurrency(records []Record) int {
+	type event struct {
+		at    time.Time
+		delta int
+	}
+	events := []event{}
+	for _, r := range records {
+		if !r.End.After(r.Start) {
+			continue
+		}
+		events = append(events, event{at: r.Start, delta: 1}, event{at: r.End, delta: -1})
+	}
+	// an object ending at the same instant another starts is not running alongside it
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].at.Equal(events[j].at) {
+			return events[i].at.Before(events[j].at)
+		}
+		return events[i].delta < events[j].delta
+	})
+	peak, running := 0, 0
+	for _, e := range events {
+		running += e.delta
+		if running > peak {
+			peak = running
+		}
+	}
+	if peak == 0 && len(records) > 0 {
+		peak = 1
+	}
+	return peak
+}
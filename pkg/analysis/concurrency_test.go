@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// concurrency is the pairwise comparison Concurrencies replaced, kept as the reference it must match: how many of
+// the objects in starts and ends, including key itself, overlap with key
+func concurrency(key string, starts map[string]time.Time, ends map[string]time.Time) int {
+	st, _ := starts[key]
+	en, _ := ends[key]
+	total := 1
+	for k, start := range starts {
+		if k == key {
+			continue
+		}
+		end, _ := ends[k]
+		if start.Equal(st) && end.Equal(en) {
+			total++
+			continue
+		}
+		if start.Before(en) && end.After(st) {
+			total++
+		}
+	}
+	return total
+}
+
+func TestConcurrenciesMatchConcurrency(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	random := rand.New(rand.NewSource(1))
+	for round := 0; round < 200; round++ {
+		starts := map[string]time.Time{}
+		ends := map[string]time.Time{}
+		// few distinct seconds, so that identical, zero length and touching objects are common
+		for i := 0; i < random.Intn(20)+1; i++ {
+			key := fmt.Sprintf("obj-%d", i)
+			start := random.Intn(10)
+			starts[key] = base.Add(time.Duration(start) * time.Second)
+			ends[key] = base.Add(time.Duration(start+random.Intn(4)) * time.Second)
+		}
+		concurrencies := Concurrencies(starts, ends)
+		for key := range starts {
+			if expected := concurrency(key, starts, ends); concurrencies[key] != expected {
+				t.Fatalf("round %d: expected concurrency %d for %s from %v to %v, got %d", round, expected, key,
+					starts[key], ends[key], concurrencies[key])
+			}
+		}
+	}
+}
+
+func TestConcurrenciesEndBeforeStart(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// unlike the pairwise comparison, which counted them wherever their start and end fell, objects ending before
+	// they start only overlap identical ones
+	starts := map[string]time.Time{
+		"running":    base,
+		"unfinished": base.Add(time.Second),
+		"inverted":   base.Add(30 * time.Second),
+		"inverted-2": base.Add(30 * time.Second),
+	}
+	ends := map[string]time.Time{
+		"running":    base.Add(time.Minute),
+		"unfinished": {},
+		"inverted":   base.Add(10 * time.Second),
+		"inverted-2": base.Add(10 * time.Second),
+	}
+
+	concurrencies := Concurrencies(starts, ends)
+	expected := map[string]int{"running": 1, "unfinished": 1, "inverted": 2, "inverted-2": 2}
+	for key, count := range expected {
+		if concurrencies[key] != count {
+			t.Errorf("expected concurrency %d for %s, got %d", count, key, concurrencies[key])
+		}
+	}
+}
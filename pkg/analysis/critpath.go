@@ -0,0 +1,243 @@
+package analysis
+
+import (
+	"fmt"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/filter"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/load"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"sort"
+	"time"
+)
+
+// CriticalPathTask is the timing of the TaskRuns of a single PipelineTask within a PipelineRun, from the first one to
+// start to the last one to end when a matrix fans the PipelineTask out
+type CriticalPathTask struct {
+	PipelineTask string `json:"pipelineTask"`
+	// TaskRun is the TaskRun of the PipelineTask that ended last
+	TaskRun  string    `json:"taskRun"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration float64   `json:"duration"`
+	// Slack is how many seconds the PipelineTask could have finished later without delaying the PipelineRun
+	Slack    float64 `json:"slack"`
+	Critical bool    `json:"critical"`
+}
+
+// CriticalPath is the chain of PipelineTasks whose TaskRuns bounded the wall clock of a PipelineRun.  The seconds
+// of the PipelineRun not spent executing the TaskRuns of the chain are its scheduling gap.
+type CriticalPath struct {
+	Record
+	Path          []string           `json:"path"`
+	Execution     float64            `json:"execution"`
+	SchedulingGap float64            `json:"schedulingGap"`
+	Tasks         []CriticalPathTask `json:"tasks"`
+}
+
+// ParseCriticalPaths loads the PipelineRuns of prSource and the TaskRuns of trSource and returns the
+// CriticalPath of each completed PipelineRun kept by opts
+func ParseCriticalPaths(prSource, trSource load.Source, opts filter.Options) ([]CriticalPath, error) {
+	prs, trs, err := parseRuns(prSource, trSource, opts)
+	if err != nil {
+		return nil, err
+	}
+	return CriticalPaths(prs, trs), nil
+}
+
+// CriticalPaths returns the CriticalPath of each of the completed PipelineRuns, sorted by PipelineRun duration.
+//
+// The dependencies between PipelineTasks come from the runAfter and result references of the resolved pipeline
+// spec, with finally tasks depending on every other task.  Starting from the TaskRun that finished last, the path
+// walks back through the dependency that finished last, which is the one that gated the start of the next task.
+// The slack of a task assumes its successors would start as long after their last dependency as they did, so the
+// tasks of the path have none.
+func CriticalPaths(prs []v1beta1.PipelineRun, trs []v1beta1.TaskRun) []CriticalPath {
+	trsByPR := map[string][]v1beta1.TaskRun{}
+	for _, tr := range trs {
+		prName := ownerName(tr.ObjectMeta, pipeline.PipelineRunLabelKey, "PipelineRun")
+		if len(prName) == 0 {
+			continue
+		}
+		prKey := fmt.Sprintf("%s:%s", tr.Namespace, prName)
+		trsByPR[prKey] = append(trsByPR[prKey], tr)
+	}
+	paths := []CriticalPath{}
+	for _, pr := range prs {
+		prKey := fmt.Sprintf("%s:%s", pr.Namespace, pr.Name)
+		paths = append(paths, criticalPath(&pr, trsByPR[prKey]))
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		if paths[i].Duration != paths[j].Duration {
+			return paths[i].Duration < paths[j].Duration
+		}
+		return paths[i].Key < paths[j].Key
+	})
+	return paths
+}
+
+func criticalPath(pr *v1beta1.PipelineRun, trs []v1beta1.TaskRun) CriticalPath {
+	start := pr.Status.StartTime.Time
+	end := pr.Status.CompletionTime.Time
+	path := CriticalPath{
+		Record: Record{
+			Key:       fmt.Sprintf("%s:%s", pr.Namespace, pr.Name),
+			Namespace: pr.Namespace,
+			Name:      pr.Name,
+			Start:     start,
+			End:       end,
+			Duration:  end.Sub(start).Seconds(),
+		},
+		Path:  []string{},
+		Tasks: []CriticalPathTask{},
+	}
+
+	// the TaskRun name to PipelineTask name mapping of the status covers TaskRuns missing the pipelineTask label
+	childToTask := map[string]string{}
+	for _, child := range pr.Status.ChildReferences {
+		childToTask[child.Name] = child.PipelineTaskName
+	}
+	for trName, trStatus := range pr.Status.TaskRuns {
+		if trStatus != nil {
+			childToTask[trName] = trStatus.PipelineTaskName
+		}
+	}
+	tasks := map[string]*CriticalPathTask{}
+	for _, tr := range trs {
+		taskName := tr.Labels[pipeline.PipelineTaskLabelKey]
+		if len(taskName) == 0 {
+			taskName = childToTask[tr.Name]
+		}
+		if len(taskName) == 0 {
+			continue
+		}
+		// the TaskRuns of a PipelineTask fanned out by a matrix span from the first to start to the last to end
+		trStart, trEnd := tr.Status.StartTime.Time, tr.Status.CompletionTime.Time
+		t, ok := tasks[taskName]
+		if !ok {
+			tasks[taskName] = &CriticalPathTask{PipelineTask: taskName, TaskRun: tr.Name, Start: trStart, End: trEnd}
+			continue
+		}
+		if trStart.Before(t.Start) {
+			t.Start = trStart
+		}
+		if trEnd.After(t.End) || (trEnd.Equal(t.End) && tr.Name < t.TaskRun) {
+			t.End = trEnd
+			t.TaskRun = tr.Name
+		}
+	}
+	if len(tasks) == 0 {
+		path.SchedulingGap = path.Duration
+		return path
+	}
+	for _, t := range tasks {
+		t.Duration = t.End.Sub(t.Start).Seconds()
+	}
+
+	deps := pipelineTaskDeps(pr)
+	successors := map[string][]string{}
+	for name, taskDeps := range deps {
+		for _, dep := range taskDeps {
+			successors[dep] = append(successors[dep], name)
+		}
+	}
+
+	// a task waits for the last of its dependencies to finish, and starts as long after it as it did, so a task
+	// finishing later delays each of its successors by the time it finishes past their last dependency, which
+	// their own slack then absorbs; tasks nothing depends on may finish as late as the last TaskRun
+	ordered := []*CriticalPathTask{}
+	for _, t := range tasks {
+		ordered = append(ordered, t)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if !ordered[i].End.Equal(ordered[j].End) {
+			return ordered[i].End.After(ordered[j].End)
+		}
+		return ordered[i].PipelineTask < ordered[j].PipelineTask
+	})
+	lastEnd := ordered[0].End
+	lastDepEnd := func(t *CriticalPathTask) time.Time {
+		end := time.Time{}
+		for _, dep := range deps[t.PipelineTask] {
+			if d, ok := tasks[dep]; ok && d.End.After(end) {
+				end = d.End
+			}
+		}
+		return end
+	}
+	slacked := map[string]bool{}
+	var slack func(t *CriticalPathTask) float64
+	slack = func(t *CriticalPathTask) float64 {
+		if slacked[t.PipelineTask] {
+			return t.Slack
+		}
+		slacked[t.PipelineTask] = true
+		t.Slack = lastEnd.Sub(t.End).Seconds()
+		for _, succ := range successors[t.PipelineTask] {
+			s, ok := tasks[succ]
+			if !ok {
+				continue
+			}
+			if viaSucc := lastDepEnd(s).Sub(t.End).Seconds() + slack(s); viaSucc < t.Slack {
+				t.Slack = viaSucc
+			}
+		}
+		return t.Slack
+	}
+	for _, t := range ordered {
+		slack(t)
+	}
+
+	// walk back from the last task to finish through the dependency that finished last
+	current := ordered[0]
+	chain := []*CriticalPathTask{}
+	for current != nil {
+		current.Critical = true
+		chain = append(chain, current)
+		var gate *CriticalPathTask
+		for _, dep := range deps[current.PipelineTask] {
+			d, ok := tasks[dep]
+			if !ok || d.Critical {
+				continue
+			}
+			if gate == nil || d.End.After(gate.End) {
+				gate = d
+			}
+		}
+		current = gate
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		path.Path = append(path.Path, chain[i].PipelineTask)
+		path.Execution += chain[i].Duration
+	}
+	path.SchedulingGap = path.Duration - path.Execution
+
+	sort.Slice(ordered, func(i, j int) bool {
+		if !ordered[i].Start.Equal(ordered[j].Start) {
+			return ordered[i].Start.Before(ordered[j].Start)
+		}
+		return ordered[i].PipelineTask < ordered[j].PipelineTask
+	})
+	for _, t := range ordered {
+		path.Tasks = append(path.Tasks, *t)
+	}
+	return path
+}
+
+// pipelineTaskDeps returns the PipelineTasks each PipelineTask of the PipelineRun depends on, preferring the
+// pipeline spec resolved into the status over the one embedded in the spec
+func pipelineTaskDeps(pr *v1beta1.PipelineRun) map[string][]string {
+	spec := pr.Status.PipelineSpec
+	if spec == nil {
+		spec = pr.Spec.PipelineSpec
+	}
+	if spec == nil {
+		return map[string][]string{}
+	}
+	deps := v1beta1.PipelineTaskList(spec.Tasks).Deps()
+	for _, finally := range spec.Finally {
+		for _, task := range spec.Tasks {
+			deps[finally.Name] = append(deps[finally.Name], task.Name)
+		}
+	}
+	return deps
+}
@@ -0,0 +1,211 @@
+package analysis
+
+import (
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+var critPathBase = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func at(seconds int) *metav1.Time {
+	return &metav1.Time{Time: critPathBase.Add(time.Duration(seconds) * time.Second)}
+}
+
+// pipelineRun returns a PipelineRun from start to end seconds running tasks, and finally tasks after them
+func pipelineRun(name string, start, end int, tasks []v1beta1.PipelineTask, finally ...v1beta1.PipelineTask) v1beta1.PipelineRun {
+	pr := v1beta1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"}}
+	pr.Status.StartTime = at(start)
+	pr.Status.CompletionTime = at(end)
+	pr.Status.PipelineSpec = &v1beta1.PipelineSpec{Tasks: tasks, Finally: finally}
+	return pr
+}
+
+// taskRun returns a TaskRun of the PipelineTask of the PipelineRun from start to end seconds; the PipelineTask label
+// is left out when the PipelineTask is empty
+func taskRun(prName, pipelineTask, name string, start, end int) v1beta1.TaskRun {
+	tr := v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: map[string]string{
+		pipeline.PipelineRunLabelKey: prName,
+	}}}
+	if len(pipelineTask) > 0 {
+		tr.Labels[pipeline.PipelineTaskLabelKey] = pipelineTask
+	}
+	tr.Status.StartTime = at(start)
+	tr.Status.CompletionTime = at(end)
+	return tr
+}
+
+// describeTasks returns the PipelineTask, TaskRun, span, slack and criticality of each task of path
+func describeTasks(path CriticalPath) []string {
+	tasks := []string{}
+	for _, t := range path.Tasks {
+		tasks = append(tasks, fmt.Sprintf("%s %s %v-%v slack %v critical %v", t.PipelineTask, t.TaskRun,
+			t.Start.Sub(critPathBase).Seconds(), t.End.Sub(critPathBase).Seconds(), t.Slack, t.Critical))
+	}
+	return tasks
+}
+
+func TestCriticalPaths(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		pr            func() v1beta1.PipelineRun
+		trs           []v1beta1.TaskRun
+		path          []string
+		execution     float64
+		schedulingGap float64
+		tasks         []string
+	}{
+		{
+			name: "diamond",
+			pr: func() v1beta1.PipelineRun {
+				return pipelineRun("pr", 0, 30, []v1beta1.PipelineTask{
+					{Name: "a"},
+					{Name: "b", RunAfter: []string{"a"}},
+					{Name: "c", RunAfter: []string{"a"}},
+					{Name: "d", RunAfter: []string{"b", "c"}},
+				})
+			},
+			trs: []v1beta1.TaskRun{
+				taskRun("pr", "a", "pr-a", 1, 5),
+				taskRun("pr", "b", "pr-b", 6, 16),
+				taskRun("pr", "c", "pr-c", 6, 9),
+				taskRun("pr", "d", "pr-d", 17, 25),
+			},
+			path:          []string{"a", "b", "d"},
+			execution:     22,
+			schedulingGap: 8,
+			tasks: []string{
+				"a pr-a 1-5 slack 0 critical true",
+				"b pr-b 6-16 slack 0 critical true",
+				"c pr-c 6-9 slack 7 critical false",
+				"d pr-d 17-25 slack 0 critical true",
+			},
+		},
+		{
+			name: "matrix fan-out",
+			pr: func() v1beta1.PipelineRun {
+				return pipelineRun("pr", 0, 30, []v1beta1.PipelineTask{
+					{Name: "a"},
+					{Name: "b", RunAfter: []string{"a"}},
+				})
+			},
+			trs: []v1beta1.TaskRun{
+				taskRun("pr", "a", "pr-a-0", 1, 5),
+				taskRun("pr", "a", "pr-a-1", 2, 10),
+				taskRun("pr", "a", "pr-a-2", 3, 10),
+				taskRun("pr", "b", "pr-b", 11, 20),
+			},
+			path:          []string{"a", "b"},
+			execution:     18,
+			schedulingGap: 12,
+			tasks: []string{
+				"a pr-a-1 1-10 slack 0 critical true",
+				"b pr-b 11-20 slack 0 critical true",
+			},
+		},
+		{
+			name: "finally",
+			pr: func() v1beta1.PipelineRun {
+				return pipelineRun("pr", 0, 20, []v1beta1.PipelineTask{
+					{Name: "a"},
+					{Name: "b"},
+				}, v1beta1.PipelineTask{Name: "cleanup"})
+			},
+			trs: []v1beta1.TaskRun{
+				taskRun("pr", "a", "pr-a", 1, 5),
+				taskRun("pr", "b", "pr-b", 1, 10),
+				taskRun("pr", "cleanup", "pr-cleanup", 12, 15),
+			},
+			path:          []string{"b", "cleanup"},
+			execution:     12,
+			schedulingGap: 8,
+			tasks: []string{
+				"a pr-a 1-5 slack 5 critical false",
+				"b pr-b 1-10 slack 0 critical true",
+				"cleanup pr-cleanup 12-15 slack 0 critical true",
+			},
+		},
+		{
+			name: "child references",
+			pr: func() v1beta1.PipelineRun {
+				pr := pipelineRun("pr", 0, 10, []v1beta1.PipelineTask{
+					{Name: "a"},
+					{Name: "b", RunAfter: []string{"a"}},
+				})
+				pr.Status.ChildReferences = []v1beta1.ChildStatusReference{
+					{Name: "pr-a", PipelineTaskName: "a"},
+					{Name: "pr-b", PipelineTaskName: "b"},
+				}
+				return pr
+			},
+			trs: []v1beta1.TaskRun{
+				taskRun("pr", "", "pr-a", 1, 4),
+				taskRun("pr", "", "pr-b", 4, 8),
+				taskRun("pr", "", "pr-unknown", 1, 9),
+			},
+			path:          []string{"a", "b"},
+			execution:     7,
+			schedulingGap: 3,
+			tasks: []string{
+				"a pr-a 1-4 slack 0 critical true",
+				"b pr-b 4-8 slack 0 critical true",
+			},
+		},
+		{
+			name: "v1beta1 status taskRuns",
+			pr: func() v1beta1.PipelineRun {
+				pr := pipelineRun("pr", 0, 10, []v1beta1.PipelineTask{
+					{Name: "a"},
+					{Name: "b"},
+				})
+				pr.Status.TaskRuns = map[string]*v1beta1.PipelineRunTaskRunStatus{
+					"pr-a": {PipelineTaskName: "a"},
+					"pr-b": {PipelineTaskName: "b"},
+				}
+				return pr
+			},
+			trs: []v1beta1.TaskRun{
+				taskRun("pr", "", "pr-a", 1, 4),
+				taskRun("pr", "", "pr-b", 1, 8),
+			},
+			path:          []string{"b"},
+			execution:     7,
+			schedulingGap: 3,
+			tasks: []string{
+				"a pr-a 1-4 slack 4 critical false",
+				"b pr-b 1-8 slack 0 critical true",
+			},
+		},
+		{
+			name: "no TaskRuns",
+			pr: func() v1beta1.PipelineRun {
+				return pipelineRun("pr", 0, 30, []v1beta1.PipelineTask{{Name: "a"}})
+			},
+			path:          []string{},
+			execution:     0,
+			schedulingGap: 30,
+			tasks:         []string{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			paths := CriticalPaths([]v1beta1.PipelineRun{test.pr()}, test.trs)
+			if len(paths) != 1 {
+				t.Fatalf("expected 1 critical path, got %d", len(paths))
+			}
+			path := paths[0]
+			if fmt.Sprint(path.Path) != fmt.Sprint(test.path) {
+				t.Errorf("expected the path %v, got %v", test.path, path.Path)
+			}
+			if path.Execution != test.execution || path.SchedulingGap != test.schedulingGap {
+				t.Errorf("expected execution %v and scheduling gap %v, got %v and %v", test.execution,
+					test.schedulingGap, path.Execution, path.SchedulingGap)
+			}
+			if tasks := describeTasks(path); fmt.Sprint(tasks) != fmt.Sprint(test.tasks) {
+				t.Errorf("expected the tasks\n%v\ngot\n%v", test.tasks, tasks)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package analysis
+
+import (
+	"fmt"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/filter"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/load"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"knative.dev/pkg/apis"
+	"os"
+	"sort"
+)
+
+// PipelineRunFailure is a failed PipelineRun along with the TaskRuns that failed within it
+type PipelineRunFailure struct {
+	Record
+	Message  string           `json:"message,omitempty"`
+	TaskRuns []TaskRunFailure `json:"taskRuns"`
+}
+
+// TaskRunFailure is a failed TaskRun along with the steps that exited with a non zero code
+type TaskRunFailure struct {
+	Name    string        `json:"name"`
+	Pod     string        `json:"pod,omitempty"`
+	Status  string        `json:"status"`
+	Message string        `json:"message,omitempty"`
+	Steps   []StepFailure `json:"steps"`
+}
+
+// StepFailure is the termination of a failed step, with the tail of the log of its container when one was found
+type StepFailure struct {
+	Name      string   `json:"name"`
+	Container string   `json:"container"`
+	ExitCode  int32    `json:"exitCode"`
+	Reason    string   `json:"reason,omitempty"`
+	Message   string   `json:"message,omitempty"`
+	Log       []string `json:"log,omitempty"`
+}
+
+// ParseFailures loads the PipelineRuns and TaskRuns provided by source and returns the failed PipelineRuns kept by
+// opts, each with its failed TaskRuns and their failed steps.  The last logLines lines of the log of each failed
+// step are taken from source as well, unless logLines is not positive.
+func ParseFailures(source load.Source, opts filter.Options, logLines int) ([]PipelineRunFailure, error) {
+	prs, trs, err := parseRuns(source, source, opts)
+	if err != nil {
+		return nil, err
+	}
+	failures := Failures(prs, trs)
+	if logLines <= 0 {
+		return failures, nil
+	}
+	for i := range failures {
+		for j := range failures[i].TaskRuns {
+			trFailure := &failures[i].TaskRuns[j]
+			for k := range trFailure.Steps {
+				step := &trFailure.Steps[k]
+				step.Log, err = source.ContainerLog(failures[i].Namespace, trFailure.Pod, step.Container, logLines)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "problem reading the log of %s:%s container %s: %s\n",
+						failures[i].Namespace, trFailure.Pod, step.Container, err.Error())
+				}
+			}
+		}
+	}
+	return failures, nil
+}
+
+// Failures returns a PipelineRunFailure for each of prs whose Succeeded condition is false, sorted by completion
+// and then by key, with the TaskRuns of trs that were created for it and failed as well
+func Failures(prs []v1beta1.PipelineRun, trs []v1beta1.TaskRun) []PipelineRunFailure {
+	trsByPR := map[string][]TaskRunFailure{}
+	for _, tr := range trs {
+		condition := tr.Status.GetCondition(apis.ConditionSucceeded)
+		if condition == nil || !condition.IsFalse() {
+			continue
+		}
+		prName := ownerName(tr.ObjectMeta, pipeline.PipelineRunLabelKey, "PipelineRun")
+		if len(prName) == 0 {
+			continue
+		}
+		trFailure := TaskRunFailure{
+			Name:    tr.Name,
+			Pod:     tr.Status.PodName,
+			Status:  filter.TaskRunStatus(&tr),
+			Message: condition.Message,
+			Steps:   []StepFailure{},
+		}
+		for _, step := range tr.Status.Steps {
+			terminated := step.Terminated
+			if terminated == nil || terminated.ExitCode == 0 {
+				continue
+			}
+			trFailure.Steps = append(trFailure.Steps, StepFailure{
+				Name:      step.Name,
+				Container: step.ContainerName,
+				ExitCode:  terminated.ExitCode,
+				Reason:    terminated.Reason,
+				Message:   terminated.Message,
+			})
+		}
+		prKey := fmt.Sprintf("%s:%s", tr.Namespace, prName)
+		trsByPR[prKey] = append(trsByPR[prKey], trFailure)
+	}
+
+	failures := []PipelineRunFailure{}
+	for _, pr := range prs {
+		condition := pr.Status.GetCondition(apis.ConditionSucceeded)
+		if condition == nil || !condition.IsFalse() {
+			continue
+		}
+		prKey := fmt.Sprintf("%s:%s", pr.Namespace, pr.Name)
+		trFailures := append([]TaskRunFailure{}, trsByPR[prKey]...)
+		sort.Slice(trFailures, func(i, j int) bool {
+			return trFailures[i].Name < trFailures[j].Name
+		})
+		failures = append(failures, PipelineRunFailure{
+			Record: Record{
+				Key:       prKey,
+				Namespace: pr.Namespace,
+				Name:      pr.Name,
+				Start:     pr.Status.StartTime.Time,
+				End:       pr.Status.CompletionTime.Time,
+				Duration:  pr.Status.CompletionTime.Sub(pr.Status.StartTime.Time).Seconds(),
+				Status:    filter.PipelineRunStatus(&pr),
+			},
+			Message:  condition.Message,
+			TaskRuns: trFailures,
+		})
+	}
+	sort.SliceStable(failures, func(i, j int) bool {
+		if !failures[i].End.Equal(failures[j].End) {
+			return failures[i].End.Before(failures[j].End)
+		}
+		return failures[i].Key < failures[j].Key
+	})
+	return failures
+}
@@ -0,0 +1,51 @@
+package analysis
+
+import (
+	"sort"
+)
+
+const (
+	GroupByPipeline  string = "pipeline"
+	GroupByTask      string = "task"
+	GroupByNamespace string = "namespace"
+
+	// noGroup is the group of records that do not have a value for what they are grouped by
+	noGroup = "<none>"
+)
+
+// GroupSummary is the Summary of the records sharing the same value for what they were grouped by
+type GroupSummary struct {
+	Group string `json:"group"`
+	Summary
+}
+
+// GroupBy splits records by their Pipeline, Task or namespace, or by the value of any other by as a label key, and
+// returns the Summary of each group sorted by group
+func GroupBy(records []Record, by string) []GroupSummary {
+	groups := map[string][]Record{}
+	for _, r := range records {
+		group := ""
+		switch by {
+		case GroupByPipeline:
+			group = r.Pipeline
+		case GroupByTask:
+			group = r.Task
+		case GroupByNamespace:
+			group = r.Namespace
+		default:
+			group = r.Labels[by]
+		}
+		if len(group) == 0 {
+			group = noGroup
+		}
+		groups[group] = append(groups[group], r)
+	}
+	summaries := make([]GroupSummary, 0, len(groups))
+	for group, groupRecords := range groups {
+		summaries = append(summaries, GroupSummary{Group: group, Summary: Summarize(groupRecords)})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Group < summaries[j].Group
+	})
+	return summaries
+}
@@ -0,0 +1,242 @@
+package analysis
+
+import (
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	corev1 "k8s.io/api/core/v1"
+	"sort"
+	"time"
+)
+
+// PodLatency splits the time from the creation of a Pod to the termination of its last container into the phases
+// reported by its conditions, so a slow Pod can be attributed to the scheduler, its init containers, pulling and
+// starting its containers, or the work of the containers themselves.  Each phase is in seconds.  ImagePull comes
+// from the events of the Pod instead, and overlaps Initialization and ContainerStart, as images are pulled right
+// before their container starts.
+type PodLatency struct {
+	Record
+	// Scheduling is from creation until the PodScheduled condition
+	Scheduling float64 `json:"scheduling"`
+	// Initialization is from scheduling until the Initialized condition, covering the init containers
+	Initialization float64 `json:"initialization"`
+	// InitContainers is the time the init containers spent running
+	InitContainers float64 `json:"initContainers"`
+	// ImagePull is the time from each Pulling event of the Pod to the Pulled event following it, so images already
+	// present on the node do not count
+	ImagePull float64 `json:"imagePull"`
+	// ContainerStart is from initialization until the containers are ready
+	ContainerStart float64 `json:"containerStart"`
+	// Execution is from the containers being ready until the last container terminated
+	Execution float64 `json:"execution"`
+}
+
+// PipelineRunLatency is the sum of each phase of the PodLatency of every Pod created for a PipelineRun
+type PipelineRunLatency struct {
+	Key            string  `json:"key"`
+	Namespace      string  `json:"namespace"`
+	Name           string  `json:"name"`
+	Pods           int     `json:"pods"`
+	Scheduling     float64 `json:"scheduling"`
+	Initialization float64 `json:"initialization"`
+	InitContainers float64 `json:"initContainers"`
+	ImagePull      float64 `json:"imagePull"`
+	ContainerStart float64 `json:"containerStart"`
+	Execution      float64 `json:"execution"`
+}
+
+// imagePullEvent is when the pull of an image of a Pod started, or finished when pulling is false
+type imagePullEvent struct {
+	at      time.Time
+	pulling bool
+}
+
+// ProcessPodLatency records the PodLatency of a completed Pod.
+//
+// A terminated Pod flips its ContainersReady condition back to false, so when the condition is not true the
+// earliest container start stands in for it.  A missing condition, or one that precedes the previous known point,
+// like a container start standing in for ContainersReady ahead of Initialized, counts as no time spent in its phase,
+// with the following phase measured from the previous known point instead.
+func (a *Analyzer) ProcessPodLatency(pod *corev1.Pod) PodLatency {
+	var scheduled, initialized, ready, end time.Time
+	for _, condition := range pod.Status.Conditions {
+		switch condition.Type {
+		case corev1.PodScheduled:
+			scheduled = condition.LastTransitionTime.Time
+		case corev1.PodInitialized:
+			initialized = condition.LastTransitionTime.Time
+		case corev1.ContainersReady:
+			if condition.Status == corev1.ConditionTrue {
+				ready = condition.LastTransitionTime.Time
+			}
+		}
+	}
+	var firstStarted time.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if terminated == nil {
+			continue
+		}
+		if terminated.FinishedAt.Time.After(end) {
+			end = terminated.FinishedAt.Time
+		}
+		if firstStarted.IsZero() || terminated.StartedAt.Time.Before(firstStarted) {
+			firstStarted = terminated.StartedAt.Time
+		}
+	}
+	if ready.IsZero() {
+		ready = firstStarted
+	}
+
+	created := pod.CreationTimestamp.Time
+	latency := PodLatency{
+		Record: Record{
+			Key:         fmt.Sprintf("%s:%s", pod.Namespace, pod.Name),
+			Namespace:   pod.Namespace,
+			Name:        pod.Name,
+			Start:       created,
+			End:         end,
+			Duration:    end.Sub(created).Seconds(),
+			PipelineRun: ownerName(pod.ObjectMeta, pipeline.PipelineRunLabelKey, "PipelineRun"),
+			TaskRun:     ownerName(pod.ObjectMeta, pipeline.TaskRunLabelKey, "TaskRun"),
+		},
+	}
+	previous := created
+	phases := []struct {
+		at      time.Time
+		seconds *float64
+	}{
+		{scheduled, &latency.Scheduling},
+		{initialized, &latency.Initialization},
+		{ready, &latency.ContainerStart},
+		{end, &latency.Execution},
+	}
+	for _, phase := range phases {
+		if phase.at.IsZero() || phase.at.Before(previous) {
+			continue
+		}
+		*phase.seconds = phase.at.Sub(previous).Seconds()
+		previous = phase.at
+	}
+	for _, status := range pod.Status.InitContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil {
+			latency.InitContainers += terminated.FinishedAt.Sub(terminated.StartedAt.Time).Seconds()
+		}
+	}
+	a.latencies[latency.Key] = latency
+	return latency
+}
+
+// ProcessEvent keeps the Pulling and Pulled events of Pods, from which PodLatencies tells how long each Pod spent
+// pulling images, whether the events come before or after their Pod
+func (a *Analyzer) ProcessEvent(event *corev1.Event) {
+	if event.InvolvedObject.Kind != "Pod" || (event.Reason != "Pulling" && event.Reason != "Pulled") {
+		return
+	}
+	key := fmt.Sprintf("%s:%s", event.InvolvedObject.Namespace, event.InvolvedObject.Name)
+	a.imagePulls[key] = append(a.imagePulls[key], imagePullEvent{at: eventTime(event), pulling: event.Reason == "Pulling"})
+}
+
+// eventTime returns when event first happened, from whichever of its timestamps is set
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// imagePull returns the seconds from each Pulling event of the Pod with key to the Pulled event following it, as the
+// kubelet pulls the images of a Pod one after the other; a Pulled event without a pull in progress is for an image
+// already present
+func (a *Analyzer) imagePull(key string) (float64, bool) {
+	events, ok := a.imagePulls[key]
+	if !ok {
+		return 0, false
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].at.Equal(events[j].at) {
+			return events[i].at.Before(events[j].at)
+		}
+		return events[i].pulling && !events[j].pulling
+	})
+	seconds := 0.0
+	var pulling time.Time
+	for _, e := range events {
+		switch {
+		case e.pulling && pulling.IsZero():
+			pulling = e.at
+		case !e.pulling && !pulling.IsZero():
+			seconds += e.at.Sub(pulling).Seconds()
+			pulling = time.Time{}
+		}
+	}
+	return seconds, true
+}
+
+// podLatencies returns the PodLatency of each Pod processed so far along with the time it spent pulling images, unsorted
+func (a *Analyzer) podLatencies() []PodLatency {
+	latencies := make([]PodLatency, 0, len(a.latencies))
+	for key, l := range a.latencies {
+		if seconds, ok := a.imagePull(key); ok {
+			l.ImagePull = seconds
+		}
+		latencies = append(latencies, l)
+	}
+	return latencies
+}
+
+// PodLatencies returns the PodLatency of each Pod processed so far, sorted by duration
+func (a *Analyzer) PodLatencies() []PodLatency {
+	startTimes := make(map[string]time.Time, len(a.latencies))
+	endTimes := make(map[string]time.Time, len(a.latencies))
+	for key, l := range a.latencies {
+		startTimes[key] = l.Start
+		endTimes[key] = l.End
+	}
+	concurrencies := Concurrencies(startTimes, endTimes)
+	latencies := a.podLatencies()
+	for i := range latencies {
+		latencies[i].Concurrency = concurrencies[latencies[i].Key]
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		if latencies[i].Duration != latencies[j].Duration {
+			return latencies[i].Duration < latencies[j].Duration
+		}
+		return latencies[i].Key < latencies[j].Key
+	})
+	return latencies
+}
+
+// LatenciesByPipelineRun sums the phases of the Pods of each PipelineRun, sorted by PipelineRun key; Pods not
+// created for a PipelineRun are left out
+func LatenciesByPipelineRun(latencies []PodLatency) []PipelineRunLatency {
+	byKey := map[string]*PipelineRunLatency{}
+	for _, l := range latencies {
+		if len(l.PipelineRun) == 0 {
+			continue
+		}
+		prKey := fmt.Sprintf("%s:%s", l.Namespace, l.PipelineRun)
+		prLatency, ok := byKey[prKey]
+		if !ok {
+			prLatency = &PipelineRunLatency{Key: prKey, Namespace: l.Namespace, Name: l.PipelineRun}
+			byKey[prKey] = prLatency
+		}
+		prLatency.Pods++
+		prLatency.Scheduling += l.Scheduling
+		prLatency.Initialization += l.Initialization
+		prLatency.InitContainers += l.InitContainers
+		prLatency.ImagePull += l.ImagePull
+		prLatency.ContainerStart += l.ContainerStart
+		prLatency.Execution += l.Execution
+	}
+	prLatencies := make([]PipelineRunLatency, 0, len(byKey))
+	for _, prLatency := range byKey {
+		prLatencies = append(prLatencies, *prLatency)
+	}
+	sort.Slice(prLatencies, func(i, j int) bool {
+		return prLatencies[i].Key < prLatencies[j].Key
+	})
+	return prLatencies
+}
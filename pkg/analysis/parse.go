@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/filter"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/load"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ParsePipelineRunList visits the PipelineRuns provided by source and returns an Analyzer holding their timings
+func ParsePipelineRunList(source load.Source, opts filter.Options) (*Analyzer, error) {
+	analyzer := NewAnalyzer()
+	err := source.Visit(load.Visitor{PipelineRun: analyzer.pipelineRunVisitor(opts)})
+	if err != nil {
+		return nil, err
+	}
+	return analyzer, nil
+}
+
+// ParseTaskRunList visits the TaskRuns provided by source and returns an Analyzer holding the timings of the
+// TaskRuns, their steps and their sidecars
+func ParseTaskRunList(source load.Source, opts filter.Options) (*Analyzer, error) {
+	analyzer := NewAnalyzer()
+	err := source.Visit(load.Visitor{TaskRun: analyzer.taskRunVisitor(opts)})
+	if err != nil {
+		return nil, err
+	}
+	return analyzer, nil
+}
+
+// ParsePodList visits the Pods provided by source, along with their events, and returns an Analyzer holding the
+// timings of the Pods, their containers and sidecars, and their scheduling latencies
+func ParsePodList(source load.Source, opts filter.Options) (*Analyzer, error) {
+	analyzer := NewAnalyzer()
+	err := source.Visit(load.Visitor{Pod: analyzer.podVisitor(opts), Event: analyzer.ProcessEvent})
+	if err != nil {
+		return nil, err
+	}
+	return analyzer, nil
+}
+
+// ParseLists is ParsePipelineRunList, ParseTaskRunList and ParsePodList of prSource, trSource and podSource at once,
+// with a source given for several kinds visited a single time for all of them
+func ParseLists(prSource, trSource, podSource load.Source, opts filter.Options) (prAnalyzer, trAnalyzer, podAnalyzer *Analyzer, err error) {
+	prAnalyzer, trAnalyzer, podAnalyzer = NewAnalyzer(), NewAnalyzer(), NewAnalyzer()
+	err = visitSources([]load.Source{prSource, trSource, podSource}, []load.Visitor{
+		{PipelineRun: prAnalyzer.pipelineRunVisitor(opts)},
+		{TaskRun: trAnalyzer.taskRunVisitor(opts)},
+		{Pod: podAnalyzer.podVisitor(opts), Event: podAnalyzer.ProcessEvent},
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return prAnalyzer, trAnalyzer, podAnalyzer, nil
+}
+
+func (a *Analyzer) pipelineRunVisitor(opts filter.Options) func(pr *v1beta1.PipelineRun) {
+	return func(pr *v1beta1.PipelineRun) {
+		if filter.IgnorePipelineRun(pr, opts) {
+			return
+		}
+		a.ProcessPipelineRun(pr)
+	}
+}
+
+func (a *Analyzer) taskRunVisitor(opts filter.Options) func(tr *v1beta1.TaskRun) {
+	return func(tr *v1beta1.TaskRun) {
+		if filter.IgnoreTaskRun(tr, opts) {
+			return
+		}
+		a.ProcessTaskRun(tr)
+		a.ProcessSteps(tr)
+		a.ProcessSidecars(tr)
+	}
+}
+
+func (a *Analyzer) podVisitor(opts filter.Options) func(pod *corev1.Pod) {
+	return func(pod *corev1.Pod) {
+		if filter.IgnorePod(pod, opts) {
+			return
+		}
+		a.ProcessPod(pod)
+		a.ProcessContainers(pod)
+		a.ProcessPodLatency(pod)
+	}
+}
+
+// visitSources visits each of sources with the matching visitor, merging the visitors of a source given several
+// times so that it is visited once
+func visitSources(sources []load.Source, visitors []load.Visitor) error {
+	distinct := []load.Source{}
+	merged := map[load.Source]*load.Visitor{}
+	for i, source := range sources {
+		v, ok := merged[source]
+		if !ok {
+			v = &load.Visitor{}
+			merged[source] = v
+			distinct = append(distinct, source)
+		}
+		if visitors[i].PipelineRun != nil {
+			v.PipelineRun = visitors[i].PipelineRun
+		}
+		if visitors[i].TaskRun != nil {
+			v.TaskRun = visitors[i].TaskRun
+		}
+		if visitors[i].Pod != nil {
+			v.Pod = visitors[i].Pod
+		}
+		if visitors[i].Event != nil {
+			v.Event = visitors[i].Event
+		}
+	}
+	for _, source := range distinct {
+		if err := source.Visit(*merged[source]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRuns loads the PipelineRuns of prSource kept by opts and the completed TaskRuns of trSource.  Every completed
+// TaskRun is kept, as the PipelineRuns already filtered the ones that matter.
+func parseRuns(prSource, trSource load.Source, opts filter.Options) ([]v1beta1.PipelineRun, []v1beta1.TaskRun, error) {
+	prs := []v1beta1.PipelineRun{}
+	trs := []v1beta1.TaskRun{}
+	err := visitSources([]load.Source{prSource, trSource}, []load.Visitor{
+		{PipelineRun: func(pr *v1beta1.PipelineRun) {
+			if filter.IgnorePipelineRun(pr, opts) {
+				return
+			}
+			prs = append(prs, *pr)
+		}},
+		{TaskRun: func(tr *v1beta1.TaskRun) {
+			if filter.IgnoreTaskRun(tr, filter.Options{}) {
+				return
+			}
+			trs = append(trs, *tr)
+		}},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return prs, trs, nil
+}
@@ -0,0 +1,62 @@
+package analysis
+
+// State is the serializable form of the records held by an Analyzer, so an analysis can be resumed
+type State struct {
+	PipelineRuns []StateRecord `json:"pipelineRuns,omitempty"`
+	TaskRuns     []StateRecord `json:"taskRuns,omitempty"`
+	Steps        []StateRecord `json:"steps,omitempty"`
+	Pods         []StateRecord `json:"pods,omitempty"`
+	Containers   []StateRecord `json:"containers,omitempty"`
+	Sidecars     []StateRecord `json:"sidecars,omitempty"`
+	PodLatencies []PodLatency  `json:"podLatencies,omitempty"`
+}
+
+// StateRecord is a Record along with its labels, which the printed records leave out but grouping by a label needs
+// once resumed
+type StateRecord struct {
+	Record
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// State returns the records processed so far
+func (a *Analyzer) State() State {
+	return State{
+		PipelineRuns: a.pipelineRuns.all(),
+		TaskRuns:     a.taskRuns.all(),
+		Steps:        a.steps.all(),
+		Pods:         a.pods.all(),
+		Containers:   a.containers.all(),
+		Sidecars:     a.sidecars.all(),
+		PodLatencies: a.podLatencies(),
+	}
+}
+
+// Restore adds the records of state to the ones processed so far, records processed again later replacing them
+func (a *Analyzer) Restore(state State) {
+	a.pipelineRuns.restore(state.PipelineRuns)
+	a.taskRuns.restore(state.TaskRuns)
+	a.steps.restore(state.Steps)
+	a.pods.restore(state.Pods)
+	a.containers.restore(state.Containers)
+	a.sidecars.restore(state.Sidecars)
+	for _, l := range state.PodLatencies {
+		a.latencies[l.Key] = l
+	}
+}
+
+// all returns the records as indexed, without computing their concurrency or sorting them
+func (t *timings) all() []StateRecord {
+	records := make([]StateRecord, 0, len(t.records))
+	for _, r := range t.records {
+		records = append(records, StateRecord{Record: r, Labels: r.Labels})
+	}
+	return records
+}
+
+// restore indexes records that already have their key and duration filled in
+func (t *timings) restore(records []StateRecord) {
+	for _, r := range records {
+		r.Record.Labels = r.Labels
+		t.records[r.Key] = r.Record
+	}
+}
@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+)
+
+// Summary holds the statistics of the durations of a set of records, along with their peak concurrency and how many
+// of them ended with each status
+type Summary struct {
+	Count           int            `json:"count"`
+	Min             float64        `json:"min"`
+	Max             float64        `json:"max"`
+	Mean            float64        `json:"mean"`
+	StdDev          float64        `json:"stdDev"`
+	P50             float64        `json:"p50"`
+	P90             float64        `json:"p90"`
+	P99             float64        `json:"p99"`
+	PeakConcurrency int            `json:"peakConcurrency"`
+	Statuses        map[string]int `json:"statuses,omitempty"`
+}
+
+// Summarize computes the Summary of records; an empty set of records yields a zero Summary
+func Summarize(records []Record) Summary {
+	summary := Summary{Count: len(records)}
+	if len(records) == 0 {
+		return summary
+	}
+	summary.Statuses = map[string]int{}
+	durations := make([]float64, 0, len(records))
+	total := float64(0)
+	for _, r := range records {
+		durations = append(durations, r.Duration)
+		total += r.Duration
+		if len(r.Status) > 0 {
+			summary.Statuses[r.Status]++
+		}
+	}
+	sort.Float64s(durations)
+	summary.Min = durations[0]
+	summary.Max = durations[len(durations)-1]
+	summary.Mean = total / float64(len(durations))
+	variance := float64(0)
+	for _, d := range durations {
+		variance += (d - summary.Mean) * (d - summary.Mean)
+	}
+	summary.StdDev = math.Sqrt(variance / float64(len(durations)))
+	summary.P50 = Percentile(durations, 50)
+	summary.P90 = Percentile(durations, 90)
+	summary.P99 = Percentile(durations, 99)
+	summary.PeakConcurrency = PeakConcurrency(records)
+	return summary
+}
+
+// Percentile returns the nearest rank percentile p, between 0 and 100, of durations, which must already be sorted
+func Percentile(durations []float64, p float64) float64 {
+	if len(durations) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(durations))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(durations) {
+		rank = len(durations)
+	}
+	return durations[rank-1]
+}
@@ -0,0 +1,236 @@
+// Package filter decides which PipelineRuns, TaskRuns and Pods take part in an analysis.
+package filter
+
+import (
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/pkg/apis"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Options restricts the objects that take part in an analysis; the zero value keeps every completed object
+type Options struct {
+	// PipelineRun is a namespace:name key that PipelineRuns must match, and TaskRuns and Pods must start with
+	PipelineRun string
+	// Since and Until bound the window that the start or completion of an object must fall within, a zero time
+	// leaving that side of the window open
+	Since time.Time
+	Until time.Time
+	// Namespace, when set, is the only namespace objects are kept from
+	Namespace string
+	// Name must match the name of PipelineRuns, and the name of the PipelineRun that TaskRuns and Pods were
+	// created for, when set
+	Name *regexp.Regexp
+	// Selector must match the labels of every object when set
+	Selector labels.Selector
+	// Statuses, when set, are the only statuses, as returned by PipelineRunStatus, TaskRunStatus and PodStatus,
+	// objects are kept with, compared case insensitively
+	Statuses []string
+}
+
+// PipelineRunStatus returns the reason of the Succeeded condition of pr, like Succeeded, Failed, Cancelled or
+// PipelineRunTimeout
+func PipelineRunStatus(pr *v1beta1.PipelineRun) string {
+	return succeededReason(pr.Status.GetCondition(apis.ConditionSucceeded))
+}
+
+// TaskRunStatus returns the reason of the Succeeded condition of tr, like Succeeded, Failed, TaskRunCancelled or
+// TaskRunTimeout
+func TaskRunStatus(tr *v1beta1.TaskRun) string {
+	return succeededReason(tr.Status.GetCondition(apis.ConditionSucceeded))
+}
+
+// PodStatus returns the reason a Pod is in its phase, like Evicted, when there is one, and otherwise its phase
+func PodStatus(pod *corev1.Pod) string {
+	if len(pod.Status.Reason) > 0 {
+		return pod.Status.Reason
+	}
+	return string(pod.Status.Phase)
+}
+
+// succeededReason returns the reason of condition, falling back to Succeeded or Failed from its status when the
+// reason is not set
+func succeededReason(condition *apis.Condition) string {
+	switch {
+	case condition == nil:
+		return ""
+	case len(condition.Reason) > 0:
+		return condition.Reason
+	case condition.IsTrue():
+		return "Succeeded"
+	case condition.IsFalse():
+		return "Failed"
+	}
+	return string(condition.Status)
+}
+
+// hasStatus returns true when status is one of the Statuses of opts, or there are none
+func (opts Options) hasStatus(status string) bool {
+	if len(opts.Statuses) == 0 {
+		return true
+	}
+	for _, s := range opts.Statuses {
+		if strings.EqualFold(s, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseName compiles pattern into the Name of Options.  A pattern wrapped in slashes, like /^build-[0-9]+$/, is a
+// regular expression, and anything else is a glob, like build-*, matched against the whole name.
+func ParseName(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return regexp.Compile(pattern[1 : len(pattern)-1])
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.Compile("^" + expr + "$")
+}
+
+// matches returns true when the namespace, labels and PipelineRun name of meta match opts
+func (opts Options) matches(meta metav1.ObjectMeta, prName string) bool {
+	if len(opts.Namespace) > 0 && meta.Namespace != opts.Namespace {
+		return false
+	}
+	if opts.Name != nil && !opts.Name.MatchString(prName) {
+		return false
+	}
+	if opts.Selector != nil && !opts.Selector.Matches(labels.Set(meta.Labels)) {
+		return false
+	}
+	return true
+}
+
+// pipelineRunName returns the name of the PipelineRun meta was created for, or its own name when unknown
+func pipelineRunName(meta metav1.ObjectMeta) string {
+	if name := meta.Labels[pipeline.PipelineRunLabelKey]; len(name) > 0 {
+		return name
+	}
+	return meta.Name
+}
+
+// ParseTime parses value as either an RFC3339 timestamp or a duration, like 2h or 30m, before now
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC3339 time nor a duration", value)
+	}
+	return now.Add(-d), nil
+}
+
+// inWindow returns true when either start or end, if known, falls within the window of opts
+func (opts Options) inWindow(start, end time.Time) bool {
+	within := func(t time.Time) bool {
+		if t.IsZero() {
+			return false
+		}
+		if !opts.Since.IsZero() && t.Before(opts.Since) {
+			return false
+		}
+		if !opts.Until.IsZero() && t.After(opts.Until) {
+			return false
+		}
+		return true
+	}
+	return within(start) || within(end)
+}
+
+// IgnorePipelineRun returns true for PipelineRuns that have not completed, whose namespace:name key does not
+// match the PipelineRun of opts when one is provided, that do not match its namespace, name, selector or statuses,
+// or that neither started nor completed within its window
+func IgnorePipelineRun(pr *v1beta1.PipelineRun, opts Options) bool {
+	prKey := fmt.Sprintf("%s:%s", pr.Namespace, pr.Name)
+	if len(opts.PipelineRun) > 0 && prKey != opts.PipelineRun {
+		return true
+	}
+	if !opts.matches(pr.ObjectMeta, pr.Name) {
+		return true
+	}
+	if !pr.HasStarted() {
+		return true
+	}
+	if !pr.IsDone() {
+		return true
+	}
+	if !opts.hasStatus(PipelineRunStatus(pr)) {
+		return true
+	}
+	if !opts.inWindow(pr.Status.StartTime.Time, pr.Status.CompletionTime.Time) {
+		return true
+	}
+	return false
+}
+
+// IgnoreTaskRun returns true for TaskRuns that have not completed, whose namespace:name key does not start with
+// the PipelineRun of opts when one is provided, that do not match its namespace, name, selector or statuses, or
+// that neither started nor completed within its window
+func IgnoreTaskRun(tr *v1beta1.TaskRun, opts Options) bool {
+	if !tr.HasStarted() {
+		return true
+	}
+	if !tr.IsDone() {
+		return true
+	}
+	trKey := fmt.Sprintf("%s:%s", tr.Namespace, tr.Name)
+	if len(opts.PipelineRun) > 0 && !strings.HasPrefix(trKey, opts.PipelineRun) {
+		return true
+	}
+	if !opts.matches(tr.ObjectMeta, pipelineRunName(tr.ObjectMeta)) {
+		return true
+	}
+	if !opts.hasStatus(TaskRunStatus(tr)) {
+		return true
+	}
+	if !opts.inWindow(tr.Status.StartTime.Time, tr.Status.CompletionTime.Time) {
+		return true
+	}
+	return false
+}
+
+// IgnorePod returns true for Pods that have not completed or were not created for a PipelineRun, whose
+// namespace:name key does not start with the PipelineRun of opts when one is provided, that do not match its
+// namespace, name, selector or statuses, or that neither started nor had their last container terminate within
+// its window
+func IgnorePod(pod *corev1.Pod, opts Options) bool {
+	if pod.Status.StartTime == nil {
+		return true
+	}
+	if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+		return true
+	}
+	_, ok := pod.Labels["tekton.dev/pipelineRun"]
+	if !ok {
+		return true
+	}
+	podKey := fmt.Sprintf("%s:%s", pod.Namespace, pod.Name)
+	if len(opts.PipelineRun) > 0 && !strings.HasPrefix(podKey, opts.PipelineRun) {
+		return true
+	}
+	if !opts.matches(pod.ObjectMeta, pipelineRunName(pod.ObjectMeta)) {
+		return true
+	}
+	if !opts.hasStatus(PodStatus(pod)) {
+		return true
+	}
+	var terminatedTime time.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil && terminated.FinishedAt.Time.After(terminatedTime) {
+			terminatedTime = terminated.FinishedAt.Time
+		}
+	}
+	if !opts.inWindow(pod.Status.StartTime.Time, terminatedTime) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package load
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walkFiles walks the file or directory tree at fileName and calls fn with the path and a reader of the content of
+// every file for which match returns true.  The .tar, .tar.gz, .tgz and .gz files found along the way, including
+// ones nested in other archives, are streamed through rather than extracted, with the path of their files being the
+// path of the archive followed by the name of the file inside of it.  The reader is only valid until fn returns.
+func walkFiles(fileName string, match func(path string) bool, fn func(path string, r io.Reader)) error {
+	paths, err := listFiles(fileName, match)
+	for _, path := range paths {
+		walkFile(path, match, fn)
+	}
+	return err
+}
+
+// listFiles returns the paths of the archives and of the files for which match returns true in the file or directory
+// tree at fileName, in lexical order
+func listFiles(fileName string, match func(path string) bool) ([]string, error) {
+	paths := []string{}
+	err := filepath.Walk(fileName, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "filepath walk error: %s\n", err.Error())
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if !IsArchive(path) && !match(path) {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	return paths, err
+}
+
+// walkFile calls walkContent with the content of the file at path
+func walkFile(path string, match func(path string) bool, fn func(path string, r io.Reader)) {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "problem reading %s: %s\n", path, err.Error())
+		return
+	}
+	defer file.Close()
+	walkContent(path, file, match, fn)
+}
+
+// IsArchive returns true for the names of the files walked into as archives, which may hold any kind of object
+func IsArchive(path string) bool {
+	return strings.HasSuffix(path, ".tar") || strings.HasSuffix(path, ".tgz") || strings.HasSuffix(path, ".gz")
+}
+
+// IsTar returns true for the names of tar archives, compressed or not, which may hold several files; a .gz that does
+// not wrap a tar is a single compressed file
+func IsTar(path string) bool {
+	return strings.HasSuffix(path, ".tar") || strings.HasSuffix(path, ".tgz") || strings.HasSuffix(path, ".tar.gz")
+}
+
+// walkContent calls fn with r when path is not an archive, and otherwise with each file the archive holds
+func walkContent(path string, r io.Reader, match func(path string) bool, fn func(path string, r io.Reader)) {
+	switch {
+	case strings.HasSuffix(path, ".tgz"):
+		uncompressed, err := gzip.NewReader(r)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "problem reading %s: %s\n", path, err.Error())
+			return
+		}
+		defer uncompressed.Close()
+		walkTar(path, uncompressed, match, fn)
+	case strings.HasSuffix(path, ".gz"):
+		// the name without .gz tells what was compressed, so a .tar.gz is then read as a .tar
+		inner := strings.TrimSuffix(path, ".gz")
+		if !IsArchive(inner) && !match(inner) {
+			return
+		}
+		uncompressed, err := gzip.NewReader(r)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "problem reading %s: %s\n", path, err.Error())
+			return
+		}
+		defer uncompressed.Close()
+		walkContent(inner, uncompressed, match, fn)
+	case strings.HasSuffix(path, ".tar"):
+		walkTar(path, r, match, fn)
+	default:
+		fn(path, r)
+	}
+}
+
+// walkTar calls walkContent for every regular file of the tar archive at path whose content is read from r
+func walkTar(path string, r io.Reader, match func(path string) bool, fn func(path string, r io.Reader)) {
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "problem reading %s: %s\n", path, err.Error())
+			return
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		entryPath := filepath.Join(path, header.Name)
+		if !IsArchive(entryPath) && !match(entryPath) {
+			continue
+		}
+		walkContent(entryPath, reader, match, fn)
+	}
+}
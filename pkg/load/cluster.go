@@ -0,0 +1,225 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// listPageSize is the number of objects requested per page when listing from the API server
+const listPageSize = 500
+
+// Cluster is a Source listing the objects of a live cluster through the Kubernetes API; Kube and Tekton may be
+// fake clientsets
+type Cluster struct {
+	Kube   kubernetes.Interface
+	Tekton versioned.Interface
+	// Namespace limits the listing to a single namespace, or all namespaces when empty
+	Namespace string
+}
+
+// NewCluster connects to the cluster of the given context of the kubeconfig file, with the usual defaults of the
+// KUBECONFIG environment variable, ~/.kube/config and the in cluster configuration when they are empty
+func NewCluster(kubeconfig, kubeContext, namespace string) (*Cluster, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	tekton, err := versioned.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Cluster{Kube: kube, Tekton: tekton, Namespace: namespace}, nil
+}
+
+// ServesV1 returns true when the cluster serves the v1 Tekton API, and false when it only serves older versions
+func (c *Cluster) ServesV1() (bool, error) {
+	_, err := c.Tekton.Discovery().ServerResourcesForGroupVersion(v1.SchemeGroupVersion.String())
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Visit lists the objects of the kinds v asks for a page at a time
+func (c *Cluster) Visit(v Visitor) error {
+	if v.PipelineRun != nil {
+		if err := c.visitPipelineRuns(v.PipelineRun); err != nil {
+			return err
+		}
+	}
+	if v.TaskRun != nil {
+		if err := c.visitTaskRuns(v.TaskRun); err != nil {
+			return err
+		}
+	}
+	if v.Pod != nil {
+		if err := c.visitPods(v.Pod); err != nil {
+			return err
+		}
+	}
+	if v.Event != nil {
+		if err := c.visitEvents(v.Event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visitPipelineRuns lists the v1 PipelineRuns of the cluster, falling back to v1beta1 for clusters not serving v1
+func (c *Cluster) visitPipelineRuns(fn func(pr *v1beta1.PipelineRun)) error {
+	err := listPages(func(opts metav1.ListOptions) (string, error) {
+		list, err := c.Tekton.TektonV1().PipelineRuns(c.Namespace).List(context.Background(), opts)
+		if err != nil {
+			return "", err
+		}
+		objs := []runtime.Object{}
+		for i := range list.Items {
+			objs = append(objs, &list.Items[i])
+		}
+		prs := PipelineRunsFromObjects(objs)
+		for i := range prs {
+			fn(&prs[i])
+		}
+		return list.Continue, nil
+	})
+	if errors.IsNotFound(err) {
+		err = listPages(func(opts metav1.ListOptions) (string, error) {
+			list, err := c.Tekton.TektonV1beta1().PipelineRuns(c.Namespace).List(context.Background(), opts)
+			if err != nil {
+				return "", err
+			}
+			for i := range list.Items {
+				fn(&list.Items[i])
+			}
+			return list.Continue, nil
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("problem listing PipelineRuns: %s", err.Error())
+	}
+	return nil
+}
+
+// visitTaskRuns lists the v1 TaskRuns of the cluster, falling back to v1beta1 for clusters not serving v1
+func (c *Cluster) visitTaskRuns(fn func(tr *v1beta1.TaskRun)) error {
+	err := listPages(func(opts metav1.ListOptions) (string, error) {
+		list, err := c.Tekton.TektonV1().TaskRuns(c.Namespace).List(context.Background(), opts)
+		if err != nil {
+			return "", err
+		}
+		objs := []runtime.Object{}
+		for i := range list.Items {
+			objs = append(objs, &list.Items[i])
+		}
+		trs := TaskRunsFromObjects(objs)
+		for i := range trs {
+			fn(&trs[i])
+		}
+		return list.Continue, nil
+	})
+	if errors.IsNotFound(err) {
+		err = listPages(func(opts metav1.ListOptions) (string, error) {
+			list, err := c.Tekton.TektonV1beta1().TaskRuns(c.Namespace).List(context.Background(), opts)
+			if err != nil {
+				return "", err
+			}
+			for i := range list.Items {
+				fn(&list.Items[i])
+			}
+			return list.Continue, nil
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("problem listing TaskRuns: %s", err.Error())
+	}
+	return nil
+}
+
+// visitPods lists the Pods of the cluster created for PipelineRuns
+func (c *Cluster) visitPods(fn func(pod *corev1.Pod)) error {
+	err := listPages(func(opts metav1.ListOptions) (string, error) {
+		opts.LabelSelector = pipeline.PipelineRunLabelKey
+		list, err := c.Kube.CoreV1().Pods(c.Namespace).List(context.Background(), opts)
+		if err != nil {
+			return "", err
+		}
+		for i := range list.Items {
+			fn(&list.Items[i])
+		}
+		return list.Continue, nil
+	})
+	if err != nil {
+		return fmt.Errorf("problem listing Pods: %s", err.Error())
+	}
+	return nil
+}
+
+// visitEvents lists the events of the cluster about Pods
+func (c *Cluster) visitEvents(fn func(event *corev1.Event)) error {
+	err := listPages(func(opts metav1.ListOptions) (string, error) {
+		opts.FieldSelector = "involvedObject.kind=Pod"
+		list, err := c.Kube.CoreV1().Events(c.Namespace).List(context.Background(), opts)
+		if err != nil {
+			return "", err
+		}
+		for i := range list.Items {
+			fn(&list.Items[i])
+		}
+		return list.Continue, nil
+	})
+	if err != nil {
+		return fmt.Errorf("problem listing Events: %s", err.Error())
+	}
+	return nil
+}
+
+// ContainerLog returns the last lines of the log of the container, or none when the Pod is gone
+func (c *Cluster) ContainerLog(ns, podName, containerName string, lines int) ([]string, error) {
+	tailLines := int64(lines)
+	buf, err := c.Kube.CoreV1().Pods(ns).GetLogs(podName, &corev1.PodLogOptions{
+		Container: containerName,
+		TailLines: &tailLines,
+	}).DoRaw(context.Background())
+	if errors.IsNotFound(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tail(string(buf), lines), nil
+}
+
+// listPages calls list with the options of each page until the API server returns no continue token
+func listPages(list func(opts metav1.ListOptions) (string, error)) error {
+	opts := metav1.ListOptions{Limit: listPageSize}
+	for {
+		next, err := list(opts)
+		if err != nil {
+			return err
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		opts.Continue = next
+	}
+}
@@ -0,0 +1,179 @@
+package load
+
+import (
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonfake "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+)
+
+func TestListPages(t *testing.T) {
+	continues := []string{}
+	pages := []string{"page2", "page3", ""}
+	err := listPages(func(opts metav1.ListOptions) (string, error) {
+		if opts.Limit != listPageSize {
+			t.Errorf("expected limit %d, got %d", listPageSize, opts.Limit)
+		}
+		continues = append(continues, opts.Continue)
+		next := pages[0]
+		pages = pages[1:]
+		return next, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	expected := []string{"", "page2", "page3"}
+	if len(continues) != len(expected) {
+		t.Fatalf("expected %d pages, got %d", len(expected), len(continues))
+	}
+	for i := range expected {
+		if continues[i] != expected[i] {
+			t.Errorf("page %d: expected continue %q, got %q", i, expected[i], continues[i])
+		}
+	}
+}
+
+func TestClusterPipelineRunPages(t *testing.T) {
+	tekton := tektonfake.NewSimpleClientset()
+	calls := 0
+	tekton.PrependReactor("list", "pipelineruns", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		list := &v1.PipelineRunList{}
+		if calls == 1 {
+			list.Continue = "page2"
+			list.Items = []v1.PipelineRun{{ObjectMeta: metav1.ObjectMeta{Name: "pr-1", Namespace: "ns"}}}
+		} else {
+			list.Items = []v1.PipelineRun{{ObjectMeta: metav1.ObjectMeta{Name: "pr-2", Namespace: "ns"}}}
+		}
+		return true, list, nil
+	})
+	cluster := &Cluster{Kube: kubefake.NewSimpleClientset(), Tekton: tekton, Namespace: "ns"}
+
+	prList, err := PipelineRuns(cluster)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 list calls, got %d", calls)
+	}
+	if len(prList.Items) != 2 || prList.Items[0].Name != "pr-1" || prList.Items[1].Name != "pr-2" {
+		t.Errorf("expected pr-1 and pr-2, got %v", prList.Items)
+	}
+	if prList.Items[0].APIVersion != v1beta1.SchemeGroupVersion.String() {
+		t.Errorf("expected the v1 PipelineRuns converted to v1beta1, got %s", prList.Items[0].APIVersion)
+	}
+}
+
+func TestClusterV1beta1Fallback(t *testing.T) {
+	tekton := tektonfake.NewSimpleClientset(
+		&v1beta1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "pr", Namespace: "ns"}},
+		&v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "tr", Namespace: "ns"}},
+	)
+	notServed := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetResource().Version != "v1" {
+			return false, nil, nil
+		}
+		return true, nil, errors.NewNotFound(schema.GroupResource{Group: "tekton.dev", Resource: action.GetResource().Resource}, "")
+	}
+	tekton.PrependReactor("list", "pipelineruns", notServed)
+	tekton.PrependReactor("list", "taskruns", notServed)
+	cluster := &Cluster{Kube: kubefake.NewSimpleClientset(), Tekton: tekton, Namespace: "ns"}
+
+	prList, err := PipelineRuns(cluster)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if len(prList.Items) != 1 || prList.Items[0].Name != "pr" {
+		t.Errorf("expected the v1beta1 PipelineRun pr, got %v", prList.Items)
+	}
+	trList, err := TaskRuns(cluster)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if len(trList.Items) != 1 || trList.Items[0].Name != "tr" {
+		t.Errorf("expected the v1beta1 TaskRun tr, got %v", trList.Items)
+	}
+}
+
+func TestClusterPodSelector(t *testing.T) {
+	kube := kubefake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "tekton-pod", Namespace: "ns",
+			Labels: map[string]string{pipeline.PipelineRunLabelKey: "pr"}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "other-pod", Namespace: "ns"}},
+	)
+	cluster := &Cluster{Kube: kube, Tekton: tektonfake.NewSimpleClientset(), Namespace: "ns"}
+
+	podList, err := Pods(cluster)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if len(podList.Items) != 1 || podList.Items[0].Name != "tekton-pod" {
+		t.Errorf("expected only tekton-pod, got %v", podList.Items)
+	}
+	for _, action := range kube.Actions() {
+		list, ok := action.(k8stesting.ListAction)
+		if !ok {
+			continue
+		}
+		if selector := list.GetListRestrictions().Labels.String(); selector != pipeline.PipelineRunLabelKey {
+			t.Errorf("expected the label selector %s, got %s", pipeline.PipelineRunLabelKey, selector)
+		}
+	}
+}
+
+func TestClusterServesV1(t *testing.T) {
+	tekton := tektonfake.NewSimpleClientset()
+	cluster := &Cluster{Kube: kubefake.NewSimpleClientset(), Tekton: tekton}
+	servesV1, err := cluster.ServesV1()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if servesV1 {
+		t.Errorf("expected a cluster without the v1 resources not to serve v1")
+	}
+
+	tekton.Resources = []*metav1.APIResourceList{{GroupVersion: v1.SchemeGroupVersion.String()}}
+	servesV1, err = cluster.ServesV1()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if !servesV1 {
+		t.Errorf("expected a cluster with the v1 resources to serve v1")
+	}
+}
+
+func TestClusterEventSelector(t *testing.T) {
+	kube := kubefake.NewSimpleClientset(
+		&corev1.Event{ObjectMeta: metav1.ObjectMeta{Name: "pulling", Namespace: "ns"}, Reason: "Pulling",
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "tekton-pod", Namespace: "ns"}},
+	)
+	cluster := &Cluster{Kube: kube, Tekton: tektonfake.NewSimpleClientset(), Namespace: "ns"}
+
+	events := []string{}
+	err := cluster.Visit(Visitor{Event: func(event *corev1.Event) {
+		events = append(events, event.Name)
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if len(events) != 1 || events[0] != "pulling" {
+		t.Errorf("expected only the pulling event, got %v", events)
+	}
+	for _, action := range kube.Actions() {
+		list, ok := action.(k8stesting.ListAction)
+		if !ok {
+			continue
+		}
+		if selector := list.GetListRestrictions().Fields.String(); selector != "involvedObject.kind=Pod" {
+			t.Errorf("expected the field selector involvedObject.kind=Pod, got %s", selector)
+		}
+	}
+}
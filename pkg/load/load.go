@@ -0,0 +1,403 @@
+// Package load reads Tekton PipelineRuns and TaskRuns, along with their Pods, from files or directory trees
+// of json/yaml artifacts.
+package load
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+func init() {
+	v1.AddToScheme(scheme.Scheme)
+	v1beta1.AddToScheme(scheme.Scheme)
+	corev1.AddToScheme(scheme.Scheme)
+}
+
+// DecodeObjects decodes the content of a file into the objects it holds, without converting between API versions;
+// typed lists and generic v1 Lists are flattened into their items, and anything not recognized by the scheme is dropped
+func DecodeObjects(buf []byte) []runtime.Object {
+	decoder := scheme.Codecs.UniversalDeserializer()
+	obj, _, err := decoder.Decode(buf, nil, nil)
+	if err != nil {
+		return nil
+	}
+	return flattenObject(obj)
+}
+
+// flattenObject returns the items of obj when it is a list, decoding the raw items of generic Lists, or else obj
+func flattenObject(obj runtime.Object) []runtime.Object {
+	if !meta.IsListType(obj) {
+		return []runtime.Object{obj}
+	}
+	items, err := meta.ExtractList(obj)
+	if err != nil {
+		return nil
+	}
+	objs := []runtime.Object{}
+	for _, item := range items {
+		// items of a generic List are left as raw bytes
+		if unknown, ok := item.(*runtime.Unknown); ok {
+			objs = append(objs, DecodeObjects(unknown.Raw)...)
+			continue
+		}
+		objs = append(objs, item)
+	}
+	return objs
+}
+
+// PipelineRunsFromObjects normalizes both v1 and v1beta1 PipelineRuns into v1beta1, ignoring any other type
+func PipelineRunsFromObjects(objs []runtime.Object) []v1beta1.PipelineRun {
+	prs := []v1beta1.PipelineRun{}
+	for _, obj := range objs {
+		switch o := obj.(type) {
+		case *v1beta1.PipelineRun:
+			prs = append(prs, *o)
+		case *v1.PipelineRun:
+			pr := v1beta1.PipelineRun{}
+			if err := pr.ConvertFrom(context.Background(), o); err != nil {
+				fmt.Fprintf(os.Stderr, "problem converting PipelineRun %s:%s from v1: %s\n", o.Namespace, o.Name, err.Error())
+				continue
+			}
+			pr.TypeMeta = metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: "PipelineRun"}
+			prs = append(prs, pr)
+		}
+	}
+	return prs
+}
+
+// TaskRunsFromObjects normalizes both v1 and v1beta1 TaskRuns into v1beta1, ignoring any other type
+func TaskRunsFromObjects(objs []runtime.Object) []v1beta1.TaskRun {
+	trs := []v1beta1.TaskRun{}
+	for _, obj := range objs {
+		switch o := obj.(type) {
+		case *v1beta1.TaskRun:
+			trs = append(trs, *o)
+		case *v1.TaskRun:
+			tr := v1beta1.TaskRun{}
+			if err := tr.ConvertFrom(context.Background(), o); err != nil {
+				fmt.Fprintf(os.Stderr, "problem converting TaskRun %s:%s from v1: %s\n", o.Namespace, o.Name, err.Error())
+				continue
+			}
+			tr.TypeMeta = metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: "TaskRun"}
+			trs = append(trs, tr)
+		}
+	}
+	return trs
+}
+
+// PodsFromObjects returns the Pods among the decoded objects
+func PodsFromObjects(objs []runtime.Object) []corev1.Pod {
+	pods := []corev1.Pod{}
+	for _, obj := range objs {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			pods = append(pods, *pod)
+		}
+	}
+	return pods
+}
+
+// call returns the call of v with obj, normalized into v1beta1, or nil when v does not ask for the kind of obj
+func (v Visitor) call(obj runtime.Object) func() {
+	objs := []runtime.Object{obj}
+	switch obj.(type) {
+	case *v1beta1.PipelineRun, *v1.PipelineRun:
+		if v.PipelineRun == nil {
+			return nil
+		}
+		prs := PipelineRunsFromObjects(objs)
+		if len(prs) == 0 {
+			return nil
+		}
+		return func() { v.PipelineRun(&prs[0]) }
+	case *v1beta1.TaskRun, *v1.TaskRun:
+		if v.TaskRun == nil {
+			return nil
+		}
+		trs := TaskRunsFromObjects(objs)
+		if len(trs) == 0 {
+			return nil
+		}
+		return func() { v.TaskRun(&trs[0]) }
+	case *corev1.Pod:
+		if v.Pod == nil {
+			return nil
+		}
+		return func() { v.Pod(obj.(*corev1.Pod)) }
+	case *corev1.Event:
+		if v.Event == nil {
+			return nil
+		}
+		return func() { v.Event(obj.(*corev1.Event)) }
+	}
+	return nil
+}
+
+// maxPendingCalls bounds how many objects of the files read ahead of their turn are held at once, so that reading
+// several large lists at once does not hold all but one of them in memory
+const maxPendingCalls = 256
+
+// visitFiles reads and decodes the files of the tree at fileName, up to workers of them at once, and calls v with
+// their objects in the walk order of the files, so that they come out in the same order however the reads interleave;
+// an object later in the walk replacing an earlier one with the same key is then the same one for any workers
+func visitFiles(fileName string, workers int, v Visitor) error {
+	paths, err := listFiles(fileName, objectFile)
+	seq := newSequencer(maxPendingCalls)
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				walkFile(paths[i], objectFile, func(path string, r io.Reader) {
+					e := DecodeStream(r, func(obj runtime.Object) {
+						if call := v.call(obj); call != nil {
+							seq.emit(i, call)
+						}
+					})
+					if e != nil {
+						fmt.Fprintf(os.Stderr, "problem decoding %s: %s\n", path, e.Error())
+					}
+				})
+				seq.finish(i)
+			}
+		}()
+	}
+	for i := range paths {
+		seq.wait(i, workers)
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return err
+}
+
+// objectFile is the match of walkFiles for the files that may hold objects, which container logs do not
+func objectFile(path string) bool {
+	return !strings.HasSuffix(path, ".log")
+}
+
+// sequencer passes on the calls for the objects of the files being read at once in the walk order of the files.  The
+// calls for the first file in walk order not yet read are made as soon as its objects are decoded, while those for
+// the files after it are held until its turn comes.  The number of files read ahead of it is bounded by wait, and
+// the number of calls held for them by maxPending, past which the files read ahead wait for their turn.
+type sequencer struct {
+	lock sync.Mutex
+	cond *sync.Cond
+	// next is the index of the file whose calls are made right away
+	next     int
+	pending  map[int][]func()
+	finished map[int]bool
+	// held is the number of calls in pending
+	held       int
+	maxPending int
+}
+
+func newSequencer(maxPending int) *sequencer {
+	s := &sequencer{pending: map[int][]func(){}, finished: map[int]bool{}, maxPending: maxPending}
+	s.cond = sync.NewCond(&s.lock)
+	return s
+}
+
+// emit makes the call for an object of the file at index, or holds it until the turn of the file, blocking while
+// maxPending calls are already held.  The file whose turn it is never blocks, so the files read ahead always get
+// their turn.
+func (s *sequencer) emit(index int, call func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for index != s.next && s.held >= s.maxPending {
+		s.cond.Wait()
+	}
+	if index == s.next {
+		call()
+		return
+	}
+	s.pending[index] = append(s.pending[index], call)
+	s.held++
+}
+
+// finish records that the file at index was read, and makes the calls held for the files whose turn then comes
+func (s *sequencer) finish(index int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.finished[index] = true
+	for s.finished[s.next] {
+		delete(s.finished, s.next)
+		s.next++
+		for _, call := range s.pending[s.next] {
+			call()
+		}
+		s.held -= len(s.pending[s.next])
+		delete(s.pending, s.next)
+	}
+	s.cond.Broadcast()
+}
+
+// wait blocks until the file at index is less than ahead files after the one whose calls are made right away
+func (s *sequencer) wait(index, ahead int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for index >= s.next+ahead {
+		s.cond.Wait()
+	}
+}
+
+// ProcessPRFiles walks the file or directory tree at fileName, and the archives within it, and collects every
+// PipelineRun found in it
+func ProcessPRFiles(fileName string) (*v1beta1.PipelineRunList, error) {
+	return PipelineRuns(NewFiles(fileName, 0))
+}
+
+// ProcessTRFiles walks the file or directory tree at fileName, and the archives within it, and collects every
+// TaskRun found in it
+func ProcessTRFiles(fileName string) (*v1beta1.TaskRunList, error) {
+	return TaskRuns(NewFiles(fileName, 0))
+}
+
+// ProcessPodFiles walks the file or directory tree at fileName, and the archives within it, and collects every Pod
+// found in it
+func ProcessPodFiles(fileName string) (*corev1.PodList, error) {
+	return Pods(NewFiles(fileName, 0))
+}
+
+// FindContainerLog returns the last lines of the first .log file under fileName, or the archives within it, for the
+// container of the Pod, as matched by logIndex.  No lines are returned when there is no such file.
+func FindContainerLog(ns, podName, containerName, fileName string, lines int) ([]string, error) {
+	index, err := newLogIndex(fileName, lines)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error finding pod logs: %s\n", err.Error())
+		return nil, err
+	}
+	return index.find(ns, podName, containerName, lines), nil
+}
+
+// logIndex holds the last lines of every .log file of a tree, read in a single walk, so that the logs of many
+// containers are found without walking the tree again for each
+type logIndex struct {
+	// lines is the number of lines kept from the end of each log
+	lines int
+	logs  []containerLog
+}
+
+type containerLog struct {
+	// dirs are the directory names of the path of the log, and name its file name without .log
+	dirs []string
+	name string
+	tail []string
+}
+
+// newLogIndex walks the tree at fileName and keeps the last lines of each .log file found in it, in walk order
+func newLogIndex(fileName string, lines int) (*logIndex, error) {
+	index := &logIndex{lines: lines}
+	err := walkFiles(fileName, func(path string) bool {
+		return strings.HasSuffix(path, ".log")
+	}, func(path string, r io.Reader) {
+		logTail, e := tailReader(r, lines)
+		if e != nil {
+			fmt.Fprintf(os.Stderr, "problem reading %s: %s\n", path, e.Error())
+			return
+		}
+		index.logs = append(index.logs, containerLog{
+			dirs: strings.Split(filepath.ToSlash(filepath.Dir(path)), "/"),
+			name: strings.TrimSuffix(filepath.Base(path), ".log"),
+			tail: logTail,
+		})
+	})
+	return index, err
+}
+
+// find returns the last lines of the first log of the container of the Pod, or none when there is no such log.  The
+// namespace and the Pod must both be directory names of the path of the log, and the log must either be named after
+// the container, or be below a directory named after the container within the one of the Pod, like the
+// namespaces/<ns>/pods/<pod>/<container>/<container>/logs/current.log of a must-gather.
+func (i *logIndex) find(ns, podName, containerName string, lines int) []string {
+	for _, log := range i.logs {
+		podDir := -1
+		nsFound := false
+		for d, dir := range log.dirs {
+			nsFound = nsFound || dir == ns
+			if dir == podName {
+				podDir = d
+			}
+		}
+		if !nsFound || podDir < 0 {
+			continue
+		}
+		found := log.name == containerName
+		for _, dir := range log.dirs[podDir+1:] {
+			found = found || dir == containerName
+		}
+		if !found {
+			continue
+		}
+		if len(log.tail) > lines {
+			return log.tail[len(log.tail)-lines:]
+		}
+		return log.tail
+	}
+	return []string{}
+}
+
+// tailReader returns the last n lines read from r, ignoring the empty lines it ends with like tail does
+func tailReader(r io.Reader, n int) ([]string, error) {
+	lines := []string{}
+	if n <= 0 {
+		return lines, nil
+	}
+	reader := bufio.NewReader(r)
+	// empty lines are only kept once a line follows them
+	empty := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			line = strings.TrimSuffix(line, "\n")
+			if len(line) == 0 {
+				empty++
+			} else {
+				for ; empty > 0; empty-- {
+					lines = append(lines, "")
+				}
+				lines = append(lines, line)
+				if len(lines) > 2*n {
+					lines = append([]string{}, lines[len(lines)-n:]...)
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+// tail returns the last n lines of content, ignoring a trailing newline
+func tail(content string, n int) []string {
+	content = strings.TrimRight(content, "\n")
+	if len(content) == 0 || n <= 0 {
+		return []string{}
+	}
+	lines := strings.Split(content, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
@@ -0,0 +1,120 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSequencerOrder(t *testing.T) {
+	seq := newSequencer(maxPendingCalls)
+	calls := []string{}
+	record := func(name string) func() {
+		return func() { calls = append(calls, name) }
+	}
+	// the files after the first are read before it, and the last one finishes first
+	seq.emit(2, record("2a"))
+	seq.emit(1, record("1a"))
+	seq.emit(2, record("2b"))
+	seq.finish(2)
+	seq.emit(1, record("1b"))
+	seq.emit(0, record("0a"))
+	seq.finish(1)
+	seq.emit(0, record("0b"))
+	seq.finish(0)
+
+	expected := []string{"0a", "0b", "1a", "1b", "2a", "2b"}
+	if fmt.Sprint(calls) != fmt.Sprint(expected) {
+		t.Errorf("expected the calls %v, got %v", expected, calls)
+	}
+	if seq.held != 0 || len(seq.pending) != 0 {
+		t.Errorf("expected no call held once every file finished, got %d", seq.held)
+	}
+}
+
+func TestSequencerPendingBound(t *testing.T) {
+	seq := newSequencer(2)
+	calls := []string{}
+	lock := sync.Mutex{}
+	record := func(name string) func() {
+		return func() {
+			lock.Lock()
+			defer lock.Unlock()
+			calls = append(calls, name)
+		}
+	}
+
+	emitted := make(chan int)
+	go func() {
+		for i := 0; i < 5; i++ {
+			seq.emit(1, record(fmt.Sprintf("1-%d", i)))
+			emitted <- i
+		}
+		seq.finish(1)
+		close(emitted)
+	}()
+	<-emitted
+	<-emitted
+	select {
+	case i := <-emitted:
+		t.Fatalf("expected the file read ahead to block once 2 calls are held, emitted call %d", i)
+	case <-time.After(50 * time.Millisecond):
+	}
+	seq.lock.Lock()
+	held := seq.held
+	seq.lock.Unlock()
+	if held != 2 {
+		t.Errorf("expected 2 calls held, got %d", held)
+	}
+
+	// the file whose turn it is never blocks
+	seq.emit(0, record("0-0"))
+	seq.finish(0)
+	for range emitted {
+	}
+
+	expected := []string{"0-0", "1-0", "1-1", "1-2", "1-3", "1-4"}
+	if fmt.Sprint(calls) != fmt.Sprint(expected) {
+		t.Errorf("expected the calls %v, got %v", expected, calls)
+	}
+}
+
+func TestVisitFilesOrder(t *testing.T) {
+	dir := t.TempDir()
+	expected := []string{}
+	for f := 0; f < 10; f++ {
+		podList := corev1.PodList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PodList"}}
+		for p := 0; p < 100; p++ {
+			name := fmt.Sprintf("pod-%d-%d", f, p)
+			podList.Items = append(podList.Items, corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"}})
+			expected = append(expected, name)
+		}
+		buf, err := json.Marshal(podList)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("pods-%d.json", f)), buf, 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	for _, workers := range []int{1, 4} {
+		podList, err := Pods(NewFiles(dir, workers))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		names := []string{}
+		for _, pod := range podList.Items {
+			names = append(names, pod.Name)
+		}
+		if fmt.Sprint(names) != fmt.Sprint(expected) {
+			t.Errorf("%d workers: expected the Pods in walk order, got %v", workers, names)
+		}
+	}
+}
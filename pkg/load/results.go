@@ -0,0 +1,149 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// resultsPageSize is the number of records requested per page from the Tekton Results API
+const resultsPageSize = 100
+
+// Results is a Source listing the PipelineRuns and TaskRuns archived by Tekton Results, through the REST API of its
+// server at URL, skipping the records that do not decode.  Results does not archive Pods, so none are ever listed.
+// Client may be any http.Client, like the one of an httptest.Server standing in for the Results API.
+type Results struct {
+	URL string
+	// Parent is the namespace whose records are listed, or - for every namespace
+	Parent string
+	// Token is the bearer token sent along with each request, when set
+	Token  string
+	Client *http.Client
+}
+
+// resultsRecord is the subset of a record of the Results API needed to decode the object it archived
+type resultsRecord struct {
+	Name string `json:"name"`
+	Data struct {
+		// Type is the apiVersion and kind of the object, like tekton.dev/v1beta1.PipelineRun
+		Type string `json:"type"`
+		// Value is the object serialized as json, base64 encoded in the json of the record
+		Value []byte `json:"value"`
+	} `json:"data"`
+}
+
+type resultsRecordList struct {
+	Records       []resultsRecord `json:"records"`
+	NextPageToken string          `json:"nextPageToken"`
+}
+
+// Visit lists the PipelineRuns and TaskRuns archived by Results a page at a time.  There are no Pods or events to
+// visit, as Results does not archive them, which is an error when only Pods are asked for.
+func (r *Results) Visit(v Visitor) error {
+	if v.Pod != nil {
+		if v.PipelineRun == nil && v.TaskRun == nil {
+			return fmt.Errorf("there are no Pods to list, as Tekton Results does not archive them")
+		}
+		fmt.Fprintf(os.Stderr, "Tekton Results does not archive Pods, so none are analyzed\n")
+	}
+	if v.PipelineRun != nil {
+		if err := r.visitRecords("PipelineRun", v); err != nil {
+			return err
+		}
+	}
+	if v.TaskRun != nil {
+		if err := r.visitRecords("TaskRun", v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ContainerLog returns no lines, as logs are not read from Results
+func (r *Results) ContainerLog(ns, podName, containerName string, lines int) ([]string, error) {
+	return []string{}, nil
+}
+
+// visitRecords pages through the records of the Tekton objects of kind, of either API version, and calls v with the
+// objects they archived
+func (r *Results) visitRecords(kind string, v Visitor) error {
+	parent := r.Parent
+	if len(parent) == 0 {
+		parent = "-"
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	filter := fmt.Sprintf(`data_type in ["tekton.dev/v1.%[1]s", "tekton.dev/v1beta1.%[1]s"]`, kind)
+	pageToken := ""
+	for {
+		query := url.Values{}
+		query.Set("filter", filter)
+		query.Set("page_size", fmt.Sprintf("%d", resultsPageSize))
+		if len(pageToken) > 0 {
+			query.Set("page_token", pageToken)
+		}
+		listURL := fmt.Sprintf("%s/apis/results.tekton.dev/v1alpha2/parents/%s/results/-/records?%s",
+			strings.TrimSuffix(r.URL, "/"), url.PathEscape(parent), query.Encode())
+		list, err := r.get(client, listURL)
+		if err != nil {
+			return fmt.Errorf("problem listing %s records from %s: %s", kind, r.URL, err.Error())
+		}
+		for _, record := range list.Records {
+			obj, err := decodeRecord(record)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "problem decoding record %s: %s\n", record.Name, err.Error())
+				continue
+			}
+			if call := v.call(obj); call != nil {
+				call()
+			}
+		}
+		if len(list.NextPageToken) == 0 {
+			return nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+func (r *Results) get(client *http.Client, listURL string) (*resultsRecordList, error) {
+	req, err := http.NewRequest(http.MethodGet, listURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	list := &resultsRecordList{}
+	if err := json.NewDecoder(resp.Body).Decode(list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// decodeRecord decodes the object archived in record, whose apiVersion and kind are taken from the type of the
+// record when the object itself leaves them out
+func decodeRecord(record resultsRecord) (runtime.Object, error) {
+	sep := strings.LastIndex(record.Data.Type, ".")
+	if sep < 0 {
+		return nil, fmt.Errorf("unexpected record type %q", record.Data.Type)
+	}
+	gvk := schema.FromAPIVersionAndKind(record.Data.Type[:sep], record.Data.Type[sep+1:])
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(record.Data.Value, &gvk, nil)
+	return obj, err
+}
@@ -0,0 +1,82 @@
+package load
+
+import (
+	"encoding/json"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func record(t *testing.T, name, dataType string, obj interface{}) resultsRecord {
+	buf, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	r := resultsRecord{Name: name}
+	r.Data.Type = dataType
+	r.Data.Value = buf
+	return r
+}
+
+func TestResultsPipelineRuns(t *testing.T) {
+	// the objects archived by Results may leave their apiVersion and kind out, which the type of the record tells
+	pages := map[string]resultsRecordList{
+		"": {
+			Records: []resultsRecord{
+				record(t, "ns/results/a/records/1", "tekton.dev/v1.PipelineRun",
+					&v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "pr-v1", Namespace: "ns"}}),
+				{Name: "ns/results/a/records/bad"},
+			},
+			NextPageToken: "page2",
+		},
+		"page2": {
+			Records: []resultsRecord{
+				record(t, "ns/results/b/records/1", "tekton.dev/v1beta1.PipelineRun",
+					&v1beta1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "pr-v1beta1", Namespace: "ns"}}),
+			},
+		},
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/apis/results.tekton.dev/v1alpha2/parents/ns/results/-/records" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer token" {
+			t.Errorf("unexpected authorization %q", auth)
+		}
+		expectedFilter := `data_type in ["tekton.dev/v1.PipelineRun", "tekton.dev/v1beta1.PipelineRun"]`
+		if filter := r.URL.Query().Get("filter"); filter != expectedFilter {
+			t.Errorf("unexpected filter %q", filter)
+		}
+		page, ok := pages[r.URL.Query().Get("page_token")]
+		if !ok {
+			http.Error(w, "unknown page", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	results := &Results{URL: server.URL, Parent: "ns", Token: "token", Client: server.Client()}
+	prList, err := PipelineRuns(results)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 pages to be requested, got %d", requests)
+	}
+	if len(prList.Items) != 2 || prList.Items[0].Name != "pr-v1" || prList.Items[1].Name != "pr-v1beta1" {
+		t.Fatalf("expected pr-v1 and pr-v1beta1, skipping the bad record, got %v", prList.Items)
+	}
+}
+
+func TestResultsPods(t *testing.T) {
+	results := &Results{URL: "http://results.invalid"}
+	if _, err := Pods(results); err == nil {
+		t.Errorf("expected an error listing only Pods, which Results does not archive")
+	}
+}
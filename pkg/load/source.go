@@ -0,0 +1,95 @@
+package load
+
+import (
+	"fmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// Visitor is called with each object of a Source as it is read, in the order of the Source; the kinds whose func is
+// nil are not read at all.  The objects must not be retained past the call, as they may be reused.
+type Visitor struct {
+	PipelineRun func(pr *v1beta1.PipelineRun)
+	TaskRun     func(tr *v1beta1.TaskRun)
+	Pod         func(pod *corev1.Pod)
+	// Event is called with the events of Pods, which tell when their images were pulled
+	Event func(event *corev1.Event)
+}
+
+// Source provides the PipelineRuns, TaskRuns, Pods and Pod events to analyze, along with the logs of their containers
+type Source interface {
+	// Visit calls v with each object of the kinds v asks for, without holding all of them in memory at once, so
+	// several kinds are read in a single pass
+	Visit(v Visitor) error
+	// ContainerLog returns the last lines of the log of a container of a Pod, or none when the log is not available
+	ContainerLog(ns, podName, containerName string, lines int) ([]string, error)
+}
+
+// PipelineRuns collects every PipelineRun of source
+func PipelineRuns(source Source) (*v1beta1.PipelineRunList, error) {
+	prList := &v1beta1.PipelineRunList{Items: []v1beta1.PipelineRun{}}
+	err := source.Visit(Visitor{PipelineRun: func(pr *v1beta1.PipelineRun) {
+		prList.Items = append(prList.Items, *pr)
+	}})
+	return prList, err
+}
+
+// TaskRuns collects every TaskRun of source
+func TaskRuns(source Source) (*v1beta1.TaskRunList, error) {
+	trList := &v1beta1.TaskRunList{Items: []v1beta1.TaskRun{}}
+	err := source.Visit(Visitor{TaskRun: func(tr *v1beta1.TaskRun) {
+		trList.Items = append(trList.Items, *tr)
+	}})
+	return trList, err
+}
+
+// Pods collects every Pod of source
+func Pods(source Source) (*corev1.PodList, error) {
+	podList := &corev1.PodList{Items: []corev1.Pod{}}
+	err := source.Visit(Visitor{Pod: func(pod *corev1.Pod) {
+		podList.Items = append(podList.Items, *pod)
+	}})
+	return podList, err
+}
+
+// Files is a Source reading the file, directory tree or archive at its path
+type Files struct {
+	path string
+	// workers is the number of files read and decoded at once
+	workers int
+
+	logLock sync.Mutex
+	logs    *logIndex
+}
+
+// NewFiles returns a Files reading the file, directory tree or archive at path with up to workers files read at
+// once, or as many as there are CPUs when workers is not positive
+func NewFiles(path string, workers int) *Files {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &Files{path: path, workers: workers}
+}
+
+func (f *Files) Visit(v Visitor) error {
+	return visitFiles(f.path, f.workers, v)
+}
+
+// ContainerLog returns the last lines of the log of the container from the logs of the tree, which are all read on
+// the first call, and again only when more lines are asked for
+func (f *Files) ContainerLog(ns, podName, containerName string, lines int) ([]string, error) {
+	f.logLock.Lock()
+	defer f.logLock.Unlock()
+	if f.logs == nil || f.logs.lines < lines {
+		logs, err := newLogIndex(f.path, lines)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error finding pod logs: %s\n", err.Error())
+			return nil, err
+		}
+		f.logs = logs
+	}
+	return f.logs.find(ns, podName, containerName, lines), nil
+}
@@ -0,0 +1,303 @@
+package load
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// DecodeStream decodes the json or yaml read from r and calls fn with each object it holds, in order.  Like
+// DecodeObjects, lists are flattened into their items and anything not recognized by the scheme is dropped, but the
+// items of the lists are decoded one at a time as they are read, so that a list is never held whole in memory.  Json
+// may be a sequence of objects or arrays of objects, and yaml may hold several documents.
+func DecodeStream(r io.Reader, fn func(obj runtime.Object)) error {
+	reader := bufio.NewReader(r)
+	for {
+		c, err := reader.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		reader.UnreadByte()
+		if c == '{' || c == '[' {
+			return decodeJSONStream(reader, fn)
+		}
+		return decodeYAMLStream(reader, fn)
+	}
+}
+
+// decodeJSONStream decodes the json objects, and the arrays of objects, read from r
+func decodeJSONStream(r io.Reader, fn func(obj runtime.Object)) error {
+	decoder := json.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'):
+			err = decodeJSONObject(decoder, fn)
+		case json.Delim('['):
+			err = decodeJSONItems(decoder, &listItems{fn: fn})
+		default:
+			err = fmt.Errorf("unexpected json %v", token)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// decodeJSONObject decodes the object whose opening brace was just read by decoder.  The items of a list are decoded
+// as they are read, while the rest of its fields are kept to tell the kind of the items that leave it out.
+func decodeJSONObject(decoder *json.Decoder, fn func(obj runtime.Object)) error {
+	fields := map[string]json.RawMessage{}
+	items := &listItems{fn: fn}
+	isList := false
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		if key == "items" {
+			token, err = decoder.Token()
+			if err != nil {
+				return err
+			}
+			if token == nil {
+				continue
+			}
+			if token != json.Delim('[') {
+				return fmt.Errorf("unexpected json %v for items", token)
+			}
+			isList = true
+			if err := decodeJSONItems(decoder, items); err != nil {
+				return err
+			}
+			continue
+		}
+		value := json.RawMessage{}
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		fields[key] = value
+		if key == "apiVersion" || key == "kind" {
+			var s string
+			json.Unmarshal(value, &s)
+			items.setListType(key, s)
+		}
+	}
+	// the closing brace
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	if isList {
+		items.flush()
+		return nil
+	}
+	buf, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	for _, obj := range DecodeObjects(buf) {
+		fn(obj)
+	}
+	return nil
+}
+
+// decodeJSONItems decodes each item of the array whose opening bracket was just read by decoder
+func decodeJSONItems(decoder *json.Decoder, items *listItems) error {
+	for decoder.More() {
+		item := json.RawMessage{}
+		if err := decoder.Decode(&item); err != nil {
+			return err
+		}
+		items.add(item)
+	}
+	// the closing bracket
+	_, err := decoder.Token()
+	return err
+}
+
+// decodeYAMLStream decodes the yaml documents read from r.  Rather than being parsed whole, the lines of a document
+// holding a list are split into the lines of each of its items, on the "- " that starts each of them below the items
+// key, and each item is decoded on its own.
+func decodeYAMLStream(r *bufio.Reader, fn func(obj runtime.Object)) error {
+	doc := &yamlDocument{items: &listItems{fn: fn}, itemIndent: -1}
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			doc.addLine(line)
+		}
+		if err == io.EOF {
+			doc.end()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// yamlDocument is the state of the yaml document whose lines are being read
+type yamlDocument struct {
+	items *listItems
+	// header is the lines of the document outside of its items
+	header strings.Builder
+	// item is the lines of the item being read, without the indentation of the list
+	item strings.Builder
+	// inItems is true while reading the items of a list, whose "- " are indented by itemIndent, or -1 before the
+	// first item
+	inItems    bool
+	isList     bool
+	itemIndent int
+}
+
+func (d *yamlDocument) addLine(line string) {
+	content := strings.TrimRight(line, "\r\n")
+	if content == "---" || strings.HasPrefix(content, "--- ") {
+		d.end()
+		return
+	}
+	trimmed := strings.TrimLeft(content, " ")
+	indent := len(content) - len(trimmed)
+	if d.inItems {
+		// lines within an item lose the indentation of the list before anything else, as the content of a block
+		// scalar may look like a comment
+		switch {
+		case (d.itemIndent < 0 || indent == d.itemIndent) && (trimmed == "-" || strings.HasPrefix(trimmed, "- ")):
+			d.flushItem()
+			d.itemIndent = indent
+			d.item.WriteString(strings.TrimPrefix(strings.TrimPrefix(line[indent:], "-"), " "))
+			return
+		case d.itemIndent >= 0 && indent > d.itemIndent:
+			if indent > d.itemIndent+2 {
+				indent = d.itemIndent + 2
+			}
+			d.item.WriteString(line[indent:])
+			return
+		case len(trimmed) == 0 || strings.HasPrefix(trimmed, "#"):
+			d.item.WriteString(line)
+			return
+		}
+		// anything else ends the items
+		d.flushItem()
+		d.inItems = false
+	}
+	if strings.HasPrefix(content, "items:") {
+		value := strings.TrimSpace(strings.TrimPrefix(content, "items:"))
+		if len(value) == 0 || strings.HasPrefix(value, "#") {
+			d.inItems = true
+			d.isList = true
+			d.itemIndent = -1
+			return
+		}
+	}
+	if indent == 0 {
+		for _, key := range []string{"apiVersion", "kind"} {
+			if strings.HasPrefix(content, key+":") {
+				value := strings.TrimSpace(strings.TrimPrefix(content, key+":"))
+				d.items.setListType(key, strings.Trim(value, `"'`))
+			}
+		}
+	}
+	d.header.WriteString(line)
+}
+
+// flushItem decodes the item read so far, converted to json first as the decoder would take an item in flow style,
+// which starts with a brace, for json
+func (d *yamlDocument) flushItem() {
+	if d.item.Len() == 0 {
+		return
+	}
+	item, err := yaml.YAMLToJSON([]byte(d.item.String()))
+	d.item.Reset()
+	if err != nil {
+		return
+	}
+	d.items.add(item)
+}
+
+// end decodes what is left of the document, whose items were all read, or the whole document when it is not a list
+func (d *yamlDocument) end() {
+	d.flushItem()
+	if d.isList {
+		d.items.flush()
+	} else if len(strings.TrimSpace(d.header.String())) > 0 {
+		for _, obj := range DecodeObjects([]byte(d.header.String())) {
+			d.items.fn(obj)
+		}
+	}
+	*d = yamlDocument{items: &listItems{fn: d.items.fn}, itemIndent: -1}
+}
+
+// listItems decodes the items of a list, taking the kind of the items that leave it out from the kind of the list.
+// The items read before the kind of the list, which may come last, are kept as they are until it is known.
+type listItems struct {
+	fn         func(obj runtime.Object)
+	apiVersion string
+	kind       string
+	pending    [][]byte
+}
+
+func (l *listItems) setListType(key, value string) {
+	if key == "apiVersion" {
+		l.apiVersion = value
+	} else {
+		l.kind = value
+	}
+}
+
+// itemType returns the type of the items of a typed list, like Pod for a PodList, or nil when it is not yet known
+func (l *listItems) itemType() *schema.GroupVersionKind {
+	if len(l.apiVersion) == 0 || !strings.HasSuffix(l.kind, "List") || l.kind == "List" {
+		return nil
+	}
+	gvk := schema.FromAPIVersionAndKind(l.apiVersion, strings.TrimSuffix(l.kind, "List"))
+	return &gvk
+}
+
+func (l *listItems) add(item []byte) {
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(item, l.itemType(), nil)
+	if err != nil {
+		if runtime.IsMissingKind(err) && l.itemType() == nil {
+			l.pending = append(l.pending, item)
+		}
+		return
+	}
+	l.emit(obj)
+}
+
+// flush decodes the items kept until the end of the list, with the kind the list turned out to have
+func (l *listItems) flush() {
+	pending := l.pending
+	l.pending = nil
+	if l.itemType() == nil {
+		return
+	}
+	for _, item := range pending {
+		l.add(item)
+	}
+}
+
+// emit calls fn with obj, or with each of its items when it is itself a list
+func (l *listItems) emit(obj runtime.Object) {
+	for _, item := range flattenObject(obj) {
+		l.fn(item)
+	}
+}
@@ -0,0 +1,180 @@
+package load
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"strings"
+	"testing"
+)
+
+// describe returns the type and name of obj, along with its script annotation when it has one
+func describe(obj runtime.Object) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return fmt.Sprintf("%T", obj)
+	}
+	if script, ok := accessor.GetAnnotations()["script"]; ok {
+		return fmt.Sprintf("%T %s %q", obj, accessor.GetName(), script)
+	}
+	return fmt.Sprintf("%T %s", obj, accessor.GetName())
+}
+
+func TestDecodeStream(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name: "kubectl list",
+			content: `apiVersion: v1
+items:
+- apiVersion: tekton.dev/v1beta1
+  kind: PipelineRun
+  metadata:
+    name: pr-a
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: pod-a
+kind: List
+metadata:
+  resourceVersion: ""
+`,
+			expected: []string{"*v1beta1.PipelineRun pr-a", "*v1.Pod pod-a"},
+		},
+		{
+			name: "typed list with the kind of the items left out",
+			content: `apiVersion: v1
+kind: PodList
+items:
+- metadata:
+    name: pod-a
+- metadata:
+    name: pod-b
+`,
+			expected: []string{"*v1.Pod pod-a", "*v1.Pod pod-b"},
+		},
+		{
+			name: "typed list with the kind after the items",
+			content: `items:
+- metadata:
+    name: pod-a
+- metadata:
+    name: pod-b
+apiVersion: v1
+kind: PodList
+`,
+			expected: []string{"*v1.Pod pod-a", "*v1.Pod pod-b"},
+		},
+		{
+			name:     "typed json list with the kind after the items",
+			content:  `{"items": [{"metadata": {"name": "pod-a"}}, {"metadata": {"name": "pod-b"}}], "apiVersion": "v1", "kind": "PodList"}`,
+			expected: []string{"*v1.Pod pod-a", "*v1.Pod pod-b"},
+		},
+		{
+			name:     "typed json list",
+			content:  `{"apiVersion": "v1", "kind": "PodList", "items": [{"metadata": {"name": "pod-a"}}]}`,
+			expected: []string{"*v1.Pod pod-a"},
+		},
+		{
+			name: "documents with a leading separator",
+			content: `---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod-a
+---
+apiVersion: v1
+kind: PodList
+items:
+- metadata:
+    name: pod-b
+--- # the last one
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod-c
+`,
+			expected: []string{"*v1.Pod pod-a", "*v1.Pod pod-b", "*v1.Pod pod-c"},
+		},
+		{
+			name: "items indented under items",
+			content: `apiVersion: v1
+kind: PodList
+items:
+  - metadata:
+      name: pod-a
+      labels:
+        app: a
+  -
+    metadata:
+      name: pod-b
+metadata: {}
+`,
+			expected: []string{"*v1.Pod pod-a", "*v1.Pod pod-b"},
+		},
+		{
+			name: "block scalars with lines starting like items and comments",
+			content: `apiVersion: v1
+kind: PodList
+items:
+  - metadata:
+      name: pod-a
+      annotations:
+        script: |
+          - not an item
+
+          # nor a comment
+  - metadata:
+      name: pod-b
+`,
+			expected: []string{`*v1.Pod pod-a "- not an item\n\n# nor a comment\n"`, "*v1.Pod pod-b"},
+		},
+		{
+			name:     "crlf line endings",
+			content:  "apiVersion: v1\r\nkind: PodList\r\nitems:\r\n- metadata:\r\n    name: pod-a\r\n- metadata:\r\n    name: pod-b\r\n",
+			expected: []string{"*v1.Pod pod-a", "*v1.Pod pod-b"},
+		},
+		{
+			name: "flow style items",
+			content: `apiVersion: v1
+kind: PodList
+items:
+- {metadata: {name: pod-a}}
+- {apiVersion: v1, kind: Pod, metadata: {name: pod-b}}
+`,
+			expected: []string{"*v1.Pod pod-a", "*v1.Pod pod-b"},
+		},
+		{
+			name: "null items",
+			content: `apiVersion: v1
+kind: PodList
+items: null
+`,
+			expected: []string{},
+		},
+		{
+			name: "empty items",
+			content: `apiVersion: v1
+kind: PodList
+items: []
+`,
+			expected: []string{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			objs := []string{}
+			err := DecodeStream(strings.NewReader(test.content), func(obj runtime.Object) {
+				objs = append(objs, describe(obj))
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if fmt.Sprint(objs) != fmt.Sprint(test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, objs)
+			}
+		})
+	}
+}
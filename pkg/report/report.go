@@ -0,0 +1,397 @@
+// Package report prints the results of an analysis in one of the supported output types.
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/analysis"
+	"io"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	OutputTypeText string = "text"
+	OutputTypeCsv  string = "csv"
+	OutputTypeJson string = "json"
+	OutputTypeYaml string = "yaml"
+)
+
+// ValidOutputType returns true if outputType is one of the supported output types
+func ValidOutputType(outputType string) bool {
+	switch outputType {
+	case OutputTypeText, OutputTypeCsv, OutputTypeJson, OutputTypeYaml:
+		return true
+	}
+	return false
+}
+
+// Printer writes headers and lines to Out in the format dictated by OutputType.  The json and yaml output types
+// are instead collected into a single document, written by Flush.
+type Printer struct {
+	OutputType string
+	Out        io.Writer
+
+	// printed tracks whether anything was written yet, so csv tables can be separated
+	printed bool
+	doc     document
+}
+
+// document is the structure of the json and yaml output types
+type document struct {
+	Records              []recordOutput                  `json:"records,omitempty"`
+	PipelineRuns         []analysis.PipelineRunBreakdown `json:"pipelineRuns,omitempty"`
+	CriticalPaths        []analysis.CriticalPath         `json:"criticalPaths,omitempty"`
+	PodLatencies         []analysis.PodLatency           `json:"podLatencies,omitempty"`
+	PipelineRunLatencies []analysis.PipelineRunLatency   `json:"pipelineRunLatencies,omitempty"`
+	Failures             []analysis.PipelineRunFailure   `json:"failures,omitempty"`
+	FailureClusters      []analysis.FailureCluster       `json:"failureClusters,omitempty"`
+	Groups               []groupOutput                   `json:"groups,omitempty"`
+	Summaries            []summaryOutput                 `json:"summaries,omitempty"`
+}
+
+type recordOutput struct {
+	Kind string `json:"kind"`
+	analysis.Record
+}
+
+type groupOutput struct {
+	Kind    string `json:"kind"`
+	GroupBy string `json:"groupBy"`
+	analysis.GroupSummary
+}
+
+type summaryOutput struct {
+	Kind string `json:"kind"`
+	analysis.Summary
+}
+
+func NewPrinter(outputType string, out io.Writer) *Printer {
+	return &Printer{OutputType: outputType, Out: out}
+}
+
+func (p *Printer) structured() bool {
+	return p.OutputType == OutputTypeJson || p.OutputType == OutputTypeYaml
+}
+
+func (p *Printer) PrintHeader(headers ...string) {
+	out := ""
+	switch p.OutputType {
+	case OutputTypeCsv:
+		for _, h := range headers {
+			if len(out) > 0 {
+				out += fmt.Sprintf(";%s", h)
+			} else {
+				out = h
+			}
+		}
+		fmt.Fprintln(p.Out, out)
+		p.printed = true
+	default:
+		// text output does not have a header, and json/yaml name each field, do not print anything
+	}
+}
+
+func (p *Printer) PrintLine(format string, values ...any) {
+	switch p.OutputType {
+	case OutputTypeCsv:
+		p.printCsv(values...)
+		return
+	case OutputTypeJson, OutputTypeYaml:
+		// json/yaml are only produced from the typed print methods
+		return
+	default:
+		fmt.Fprintf(p.Out, format, values...)
+	}
+	p.printed = true
+}
+
+// printCsv writes values as a single csv line, quoting the values holding separators, quotes or line breaks
+func (p *Printer) printCsv(values ...any) {
+	fields := make([]string, 0, len(values))
+	for _, v := range values {
+		fields = append(fields, fmt.Sprintf("%v", v))
+	}
+	writer := csv.NewWriter(p.Out)
+	writer.Comma = ';'
+	writer.Write(fields)
+	writer.Flush()
+	p.printed = true
+}
+
+// PrintList prints the timing and concurrency of each record; in csv the list is separated from any preceding one by
+// an empty line
+func (p *Printer) PrintList(resource string, records []analysis.Record) {
+	if p.structured() {
+		for _, r := range records {
+			p.doc.Records = append(p.doc.Records, recordOutput{Kind: resource, Record: r})
+		}
+		return
+	}
+	if p.OutputType == OutputTypeCsv && p.printed {
+		fmt.Fprintln(p.Out)
+	}
+	p.PrintHeader(resource, "Duration", "Concurrency", "Status")
+	for _, r := range records {
+		p.PrintLine(fmt.Sprintf("%s %%s\t\ttook %%v seconds concurrency %%d status %%s\n", resource), r.Key, r.Duration, r.Concurrency, r.Status)
+	}
+}
+
+// PrintBreakdown prints each PipelineRun along with the totals of its TaskRuns and Pods
+func (p *Printer) PrintBreakdown(breakdowns []analysis.PipelineRunBreakdown) {
+	if p.structured() {
+		p.doc.PipelineRuns = append(p.doc.PipelineRuns, breakdowns...)
+		return
+	}
+	p.PrintHeader("PipelineRun", "Duration", "Concurrency", "Status", "TaskRunsDuration", "TaskRunsDelta", "TaskRunsPercentage", "TaskRunsMaxConcurrency", "PodsDuration", "PodsDelta", "PodsPercentage", "PodsMaxConcurrency", "PodsInitOverhead")
+	for _, b := range breakdowns {
+		p.PrintLine("PipelineRun %s\t\t took %v seconds with pr concurrency %d status %s with taskruns %v seconds delta %v percent %f taskrun max concurrency %d pods %v seconds delta %v percent %f pod max concurrency %d init overhead %v seconds\n",
+			b.Key,
+			b.Duration,
+			b.Concurrency,
+			b.Status,
+			b.TaskRunsDuration,
+			b.TaskRunsDelta,
+			b.TaskRunsPercentage,
+			b.TaskRunsMaxConcurrency,
+			b.PodsDuration,
+			b.PodsDelta,
+			b.PodsPercentage,
+			b.PodsMaxConcurrency,
+			b.PodsInitOverhead)
+	}
+}
+
+// PrintCriticalPaths prints the critical path of each PipelineRun followed by the timing and slack of each of its
+// PipelineTasks; csv repeats the PipelineRun columns on the line of each PipelineTask
+func (p *Printer) PrintCriticalPaths(paths []analysis.CriticalPath) {
+	if p.structured() {
+		p.doc.CriticalPaths = append(p.doc.CriticalPaths, paths...)
+		return
+	}
+	p.PrintHeader("PipelineRun", "Duration", "Execution", "SchedulingGap", "PipelineTask", "TaskRun", "TaskRunDuration", "Slack", "Critical")
+	for _, cp := range paths {
+		if p.OutputType == OutputTypeText {
+			fmt.Fprintf(p.Out, "PipelineRun %s\t\t took %v seconds critical path %s execution %v seconds scheduling gap %v seconds\n",
+				cp.Key, cp.Duration, strings.Join(cp.Path, " -> "), cp.Execution, cp.SchedulingGap)
+		}
+		for _, t := range cp.Tasks {
+			p.PrintLine("    PipelineTask %[5]s TaskRun %[6]s took %[7]v seconds slack %[8]v seconds critical %[9]t\n",
+				cp.Key,
+				cp.Duration,
+				cp.Execution,
+				cp.SchedulingGap,
+				t.PipelineTask,
+				t.TaskRun,
+				t.Duration,
+				t.Slack,
+				t.Critical)
+		}
+	}
+}
+
+// PrintPodLatencies prints how long each Pod spent being scheduled, initialized, pulling images, starting its containers
+// and executing
+func (p *Printer) PrintPodLatencies(latencies []analysis.PodLatency) {
+	if p.structured() {
+		p.doc.PodLatencies = append(p.doc.PodLatencies, latencies...)
+		return
+	}
+	p.PrintHeader("Pod", "Duration", "Scheduling", "Initialization", "InitContainers", "ImagePull", "ContainerStart", "Execution")
+	for _, l := range latencies {
+		p.PrintLine("Pod %s\t\t took %v seconds scheduling %v initialization %v init containers %v image pull %v container start %v execution %v\n",
+			l.Key,
+			l.Duration,
+			l.Scheduling,
+			l.Initialization,
+			l.InitContainers,
+			l.ImagePull,
+			l.ContainerStart,
+			l.Execution)
+	}
+}
+
+// PrintPipelineRunLatencies prints the latency phases summed over the Pods of each PipelineRun; in csv the table is
+// separated from any preceding one by an empty line
+func (p *Printer) PrintPipelineRunLatencies(latencies []analysis.PipelineRunLatency) {
+	if p.structured() {
+		p.doc.PipelineRunLatencies = append(p.doc.PipelineRunLatencies, latencies...)
+		return
+	}
+	if p.OutputType == OutputTypeCsv && p.printed {
+		fmt.Fprintln(p.Out)
+	}
+	p.PrintHeader("PipelineRun", "Pods", "Scheduling", "Initialization", "InitContainers", "ImagePull", "ContainerStart", "Execution")
+	for _, l := range latencies {
+		p.PrintLine("PipelineRun %s\t\t pods %d scheduling %v initialization %v init containers %v image pull %v container start %v execution %v\n",
+			l.Key,
+			l.Pods,
+			l.Scheduling,
+			l.Initialization,
+			l.InitContainers,
+			l.ImagePull,
+			l.ContainerStart,
+			l.Execution)
+	}
+}
+
+// PrintGroups prints the duration statistics and peak concurrency of each group of a resource kind; in csv the
+// groups are separated from any preceding table by an empty line
+func (p *Printer) PrintGroups(resource, groupBy string, groups []analysis.GroupSummary) {
+	if p.structured() {
+		for _, g := range groups {
+			p.doc.Groups = append(p.doc.Groups, groupOutput{Kind: resource, GroupBy: groupBy, GroupSummary: g})
+		}
+		return
+	}
+	if p.OutputType == OutputTypeCsv && p.printed {
+		fmt.Fprintln(p.Out)
+	}
+	p.PrintHeader("Resource", "GroupBy", "Group", "Count", "Min", "Max", "Mean", "StdDev", "P50", "P90", "P99", "PeakConcurrency", "Statuses")
+	for _, g := range groups {
+		p.PrintLine("%s %s %s\t\tcount %d min %v max %v mean %f stddev %f p50 %v p90 %v p99 %v peak concurrency %d statuses %s\n",
+			resource,
+			groupBy,
+			g.Group,
+			g.Count,
+			g.Min,
+			g.Max,
+			g.Mean,
+			g.StdDev,
+			g.P50,
+			g.P90,
+			g.P99,
+			g.PeakConcurrency,
+			formatStatuses(g.Statuses))
+	}
+}
+
+// PrintFailures prints each failed PipelineRun, its failed TaskRuns and their failed steps along with the tail of the
+// log of each step; csv has a line per failed step, or per failed TaskRun or PipelineRun without any, with the log
+// lines separated by " | "
+func (p *Printer) PrintFailures(failures []analysis.PipelineRunFailure) {
+	if p.structured() {
+		p.doc.Failures = append(p.doc.Failures, failures...)
+		return
+	}
+	if p.OutputType == OutputTypeCsv {
+		p.PrintHeader("PipelineRun", "Status", "Message", "TaskRun", "Pod", "TaskRunStatus", "TaskRunMessage", "Step", "Container", "ExitCode", "Reason", "StepMessage", "Log")
+		for _, f := range failures {
+			if len(f.TaskRuns) == 0 {
+				p.printCsv(f.Key, f.Status, f.Message, "", "", "", "", "", "", "", "", "", "")
+			}
+			for _, tr := range f.TaskRuns {
+				if len(tr.Steps) == 0 {
+					p.printCsv(f.Key, f.Status, f.Message, tr.Name, tr.Pod, tr.Status, tr.Message, "", "", "", "", "", "")
+				}
+				for _, step := range tr.Steps {
+					p.printCsv(f.Key, f.Status, f.Message, tr.Name, tr.Pod, tr.Status, tr.Message,
+						step.Name, step.Container, step.ExitCode, step.Reason, step.Message, strings.Join(step.Log, " | "))
+				}
+			}
+		}
+		return
+	}
+	for _, f := range failures {
+		fmt.Fprintf(p.Out, "PipelineRun %s failed with status %s: %s\n", f.Key, f.Status, f.Message)
+		for _, tr := range f.TaskRuns {
+			fmt.Fprintf(p.Out, "    TaskRun %s pod %s failed with status %s: %s\n", tr.Name, tr.Pod, tr.Status, tr.Message)
+			for _, step := range tr.Steps {
+				fmt.Fprintf(p.Out, "        step %s container %s exited with code %d reason %s: %s\n",
+					step.Name, step.Container, step.ExitCode, step.Reason, step.Message)
+				for _, line := range step.Log {
+					fmt.Fprintf(p.Out, "            %s\n", line)
+				}
+			}
+		}
+	}
+	p.printed = true
+}
+
+// PrintFailureClusters prints each cluster of failures with its count, when it first and last occurred and
+// example PipelineRuns
+func (p *Printer) PrintFailureClusters(clusters []analysis.FailureCluster) {
+	if p.structured() {
+		p.doc.FailureClusters = append(p.doc.FailureClusters, clusters...)
+		return
+	}
+	p.PrintHeader("Count", "Reason", "ExitCode", "Message", "FirstOccurrence", "LastOccurrence", "Examples")
+	for _, c := range clusters {
+		p.PrintLine("Failure count %d reason %s exit code %d message %q first %s last %s examples %s\n",
+			c.Count,
+			c.Reason,
+			c.ExitCode,
+			c.Message,
+			c.FirstOccurrence.Format(time.RFC3339),
+			c.LastOccurrence.Format(time.RFC3339),
+			strings.Join(c.Examples, ","))
+	}
+}
+
+// PrintSummary prints the duration statistics, peak concurrency and status counts of each resource kind; in csv the summary is
+// separated from any preceding list by an empty line
+func (p *Printer) PrintSummary(resources []string, summaries []analysis.Summary) {
+	if p.structured() {
+		for i, resource := range resources {
+			p.doc.Summaries = append(p.doc.Summaries, summaryOutput{Kind: resource, Summary: summaries[i]})
+		}
+		return
+	}
+	if p.OutputType == OutputTypeCsv && p.printed {
+		fmt.Fprintln(p.Out)
+	}
+	p.PrintHeader("Resource", "Count", "Min", "Max", "Mean", "StdDev", "P50", "P90", "P99", "PeakConcurrency", "Statuses")
+	for i, resource := range resources {
+		summary := summaries[i]
+		p.PrintLine("%s summary count %d min %v max %v mean %f stddev %f p50 %v p90 %v p99 %v peak concurrency %d statuses %s\n",
+			resource,
+			summary.Count,
+			summary.Min,
+			summary.Max,
+			summary.Mean,
+			summary.StdDev,
+			summary.P50,
+			summary.P90,
+			summary.P99,
+			summary.PeakConcurrency,
+			formatStatuses(summary.Statuses))
+	}
+}
+
+// formatStatuses returns the count of each status as status=count pairs, sorted by status and separated by commas
+func formatStatuses(statuses map[string]int) string {
+	names := make([]string, 0, len(statuses))
+	for status := range statuses {
+		names = append(names, status)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, status := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", status, statuses[status]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Flush writes the document collected for the json and yaml output types; text and csv are written as they are
+// printed, so there is nothing to flush
+func (p *Printer) Flush() error {
+	var buf []byte
+	var err error
+	switch p.OutputType {
+	case OutputTypeJson:
+		buf, err = json.MarshalIndent(p.doc, "", "  ")
+		buf = append(buf, '\n')
+	case OutputTypeYaml:
+		buf, err = yaml.Marshal(p.doc)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = p.Out.Write(buf)
+	return err
+}
@@ -0,0 +1,182 @@
+// Package watch accumulates the timings of PipelineRuns, TaskRuns and Pods from informers as they complete.
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/analysis"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/filter"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/load"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	tektoninformers "github.com/tektoncd/pipeline/pkg/client/informers/externalversions"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"os"
+	"sync"
+	"time"
+)
+
+// Watcher feeds the PipelineRuns, TaskRuns and Pods of informers into an Analyzer once they complete, optionally
+// persisting the accumulated records to StateFile so a restarted Watcher resumes where it left off
+type Watcher struct {
+	Options   filter.Options
+	StateFile string
+	// V1beta1 registers on the v1beta1 PipelineRun and TaskRun informers instead of the v1 ones, for clusters not
+	// serving v1
+	V1beta1 bool
+
+	lock     sync.Mutex
+	analyzer *analysis.Analyzer
+}
+
+// NewWatcher returns a Watcher with the records of stateFile, when it is set and exists
+func NewWatcher(opts filter.Options, stateFile string) (*Watcher, error) {
+	w := &Watcher{Options: opts, StateFile: stateFile, analyzer: analysis.NewAnalyzer()}
+	if len(stateFile) == 0 {
+		return w, nil
+	}
+	buf, err := os.ReadFile(stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := analysis.State{}
+	if err := json.Unmarshal(buf, &state); err != nil {
+		return nil, fmt.Errorf("problem decoding state file %s: %s", stateFile, err.Error())
+	}
+	w.analyzer.Restore(state)
+	return w, nil
+}
+
+// NewFactories returns the informer factories of the PipelineRuns and TaskRuns, and of the Pods created for
+// PipelineRuns, of the namespace of cluster, or of all namespaces when it has none
+func NewFactories(cluster *load.Cluster) (tektoninformers.SharedInformerFactory, kubeinformers.SharedInformerFactory) {
+	tektonFactory := tektoninformers.NewSharedInformerFactoryWithOptions(cluster.Tekton, 0,
+		tektoninformers.WithNamespace(cluster.Namespace))
+	kubeFactory := kubeinformers.NewSharedInformerFactoryWithOptions(cluster.Kube, 0,
+		kubeinformers.WithNamespace(cluster.Namespace),
+		kubeinformers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = pipeline.PipelineRunLabelKey
+		}))
+	return tektonFactory, kubeFactory
+}
+
+// Register adds the handlers of the Watcher to the PipelineRun and TaskRun informers of tektonFactory and the Pod
+// informer of kubeFactory, which may be built on fake clientsets; the factories still have to be started
+func (w *Watcher) Register(tektonFactory tektoninformers.SharedInformerFactory, kubeFactory kubeinformers.SharedInformerFactory) {
+	if w.V1beta1 {
+		tektonFactory.Tekton().V1beta1().PipelineRuns().Informer().AddEventHandler(w.handler(w.processPipelineRun))
+		tektonFactory.Tekton().V1beta1().TaskRuns().Informer().AddEventHandler(w.handler(w.processTaskRun))
+	} else {
+		tektonFactory.Tekton().V1().PipelineRuns().Informer().AddEventHandler(w.handler(w.processPipelineRun))
+		tektonFactory.Tekton().V1().TaskRuns().Informer().AddEventHandler(w.handler(w.processTaskRun))
+	}
+	kubeFactory.Core().V1().Pods().Informer().AddEventHandler(w.handler(w.processPod))
+}
+
+// handler calls process for every added or updated object, as an object is usually added before it completes
+func (w *Watcher) handler(process func(obj runtime.Object)) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if o, ok := obj.(runtime.Object); ok {
+				process(o)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if o, ok := newObj.(runtime.Object); ok {
+				process(o)
+			}
+		},
+	}
+}
+
+func (w *Watcher) processPipelineRun(obj runtime.Object) {
+	for _, pr := range load.PipelineRunsFromObjects([]runtime.Object{obj}) {
+		if filter.IgnorePipelineRun(&pr, w.Options) {
+			continue
+		}
+		w.lock.Lock()
+		w.analyzer.ProcessPipelineRun(&pr)
+		w.lock.Unlock()
+	}
+}
+
+func (w *Watcher) processTaskRun(obj runtime.Object) {
+	for _, tr := range load.TaskRunsFromObjects([]runtime.Object{obj}) {
+		if filter.IgnoreTaskRun(&tr, w.Options) {
+			continue
+		}
+		w.lock.Lock()
+		w.analyzer.ProcessTaskRun(&tr)
+		w.analyzer.ProcessSteps(&tr)
+		w.analyzer.ProcessSidecars(&tr)
+		w.lock.Unlock()
+	}
+}
+
+func (w *Watcher) processPod(obj runtime.Object) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || filter.IgnorePod(pod, w.Options) {
+		return
+	}
+	w.lock.Lock()
+	w.analyzer.ProcessPod(pod)
+	w.analyzer.ProcessContainers(pod)
+	w.analyzer.ProcessPodLatency(pod)
+	w.lock.Unlock()
+}
+
+// Records returns the records of the PipelineRuns, TaskRuns and Pods that completed so far
+func (w *Watcher) Records() (prRecords, trRecords, podRecords []analysis.Record) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.analyzer.PipelineRuns(), w.analyzer.TaskRuns(), w.analyzer.Pods()
+}
+
+// Save writes the records accumulated so far to the StateFile, if any, replacing it only once fully written
+func (w *Watcher) Save() error {
+	if len(w.StateFile) == 0 {
+		return nil
+	}
+	w.lock.Lock()
+	buf, err := json.Marshal(w.analyzer.State())
+	w.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := w.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.StateFile)
+}
+
+// Run registers the Watcher with the factories, starts them and calls report every interval, and once more when
+// stop is closed, after saving the state
+func (w *Watcher) Run(tektonFactory tektoninformers.SharedInformerFactory, kubeFactory kubeinformers.SharedInformerFactory,
+	interval time.Duration, stop <-chan struct{}, report func(w *Watcher)) error {
+	w.Register(tektonFactory, kubeFactory)
+	tektonFactory.Start(stop)
+	kubeFactory.Start(stop)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.Save(); err != nil {
+				fmt.Fprintf(os.Stderr, "problem saving state file %s: %s\n", w.StateFile, err.Error())
+			}
+			report(w)
+		case <-stop:
+			err := w.Save()
+			report(w)
+			return err
+		}
+	}
+}
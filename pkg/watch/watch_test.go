@@ -0,0 +1,153 @@
+package watch
+
+import (
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/analysis"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/filter"
+	"github.com/gabemontero/tekton-artifact-performance-analysis/pkg/load"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	tektonfake "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end   = start.Add(time.Minute)
+)
+
+func succeeded() duckv1.Status {
+	return duckv1.Status{Conditions: duckv1.Conditions{{
+		Type:   apis.ConditionSucceeded,
+		Status: corev1.ConditionTrue,
+		Reason: "Succeeded",
+	}}}
+}
+
+func objectMeta(name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: map[string]string{
+		pipeline.PipelineRunLabelKey: "pr",
+		"team":                       "build",
+	}}
+}
+
+func completedPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: objectMeta("pr-task-pod"),
+		Status: corev1.PodStatus{
+			Phase:     corev1.PodSucceeded,
+			StartTime: &metav1.Time{Time: start},
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "step-build",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					StartedAt:  metav1.Time{Time: start},
+					FinishedAt: metav1.Time{Time: end},
+				}},
+			}},
+		},
+	}
+}
+
+func v1Objects() []runtime.Object {
+	pr := &v1.PipelineRun{ObjectMeta: objectMeta("pr")}
+	pr.Status.Status = succeeded()
+	pr.Status.StartTime = &metav1.Time{Time: start}
+	pr.Status.CompletionTime = &metav1.Time{Time: end}
+	tr := &v1.TaskRun{ObjectMeta: objectMeta("pr-task")}
+	tr.Status.Status = succeeded()
+	tr.Status.StartTime = &metav1.Time{Time: start}
+	tr.Status.CompletionTime = &metav1.Time{Time: end}
+	return []runtime.Object{pr, tr}
+}
+
+func v1beta1Objects() []runtime.Object {
+	pr := &v1beta1.PipelineRun{ObjectMeta: objectMeta("pr")}
+	pr.Status.Status = succeeded()
+	pr.Status.StartTime = &metav1.Time{Time: start}
+	pr.Status.CompletionTime = &metav1.Time{Time: end}
+	tr := &v1beta1.TaskRun{ObjectMeta: objectMeta("pr-task")}
+	tr.Status.Status = succeeded()
+	tr.Status.StartTime = &metav1.Time{Time: start}
+	tr.Status.CompletionTime = &metav1.Time{Time: end}
+	return []runtime.Object{pr, tr}
+}
+
+// watchUntilRecorded runs w on fake factories holding the objects until it has a record of each kind
+func watchUntilRecorded(t *testing.T, w *Watcher, tektonObjects []runtime.Object) {
+	cluster := &load.Cluster{
+		Kube:      kubefake.NewSimpleClientset(completedPod()),
+		Tekton:    tektonfake.NewSimpleClientset(tektonObjects...),
+		Namespace: "ns",
+	}
+	tektonFactory, kubeFactory := NewFactories(cluster)
+	w.Register(tektonFactory, kubeFactory)
+	stop := make(chan struct{})
+	defer close(stop)
+	tektonFactory.Start(stop)
+	kubeFactory.Start(stop)
+	tektonFactory.WaitForCacheSync(stop)
+	kubeFactory.WaitForCacheSync(stop)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		prRecords, trRecords, podRecords := w.Records()
+		if len(prRecords) == 1 && len(trRecords) == 1 && len(podRecords) == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a record of each kind, got %d PipelineRuns, %d TaskRuns and %d Pods",
+				len(prRecords), len(trRecords), len(podRecords))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		v1beta1       bool
+		tektonObjects []runtime.Object
+	}{
+		{name: "v1", tektonObjects: v1Objects()},
+		{name: "v1beta1", v1beta1: true, tektonObjects: v1beta1Objects()},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stateFile := filepath.Join(t.TempDir(), "state.json")
+			w, err := NewWatcher(filter.Options{}, stateFile)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			w.V1beta1 = test.v1beta1
+			watchUntilRecorded(t, w, test.tektonObjects)
+			if err := w.Save(); err != nil {
+				t.Fatalf("unexpected error saving the state: %s", err.Error())
+			}
+
+			resumed, err := NewWatcher(filter.Options{}, stateFile)
+			if err != nil {
+				t.Fatalf("unexpected error resuming from the state: %s", err.Error())
+			}
+			prRecords, trRecords, podRecords := resumed.Records()
+			for kind, records := range map[string][]analysis.Record{"PipelineRun": prRecords, "TaskRun": trRecords, "Pod": podRecords} {
+				if len(records) != 1 {
+					t.Fatalf("expected 1 resumed %s record, got %d", kind, len(records))
+				}
+				if records[0].Duration != 60 {
+					t.Errorf("expected the resumed %s record to take 60 seconds, got %f", kind, records[0].Duration)
+				}
+				groups := analysis.GroupBy(records, "team")
+				if len(groups) != 1 || groups[0].Group != "build" {
+					t.Errorf("expected the resumed %s record in the build team group, got %v", kind, groups)
+				}
+			}
+		})
+	}
+}